@@ -0,0 +1,152 @@
+package pkgconfig
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// ArchiveExts lists extensions of the library archives, in the order they're
+// tried when downloading from a project release. The xz compression is not
+// supported, as there's no implementation of it in the standard library.
+var ArchiveExts = []string{".zip", ".tar.gz", ".tgz", ".tar.bz2"}
+
+// entry is a single file, directory or symlink within a library archive.
+type entry struct {
+	name string
+	mode os.FileMode
+	size int64 // declared size of the unpacked content
+	link string
+	r    io.Reader
+}
+
+var errFormat = errors.New("unrecognized archive format")
+
+var magic = []struct {
+	p    []byte
+	walk func(*os.File, func(*entry) error) error
+}{
+	{[]byte("PK\x03\x04"), walkZip},
+	{[]byte("\x1f\x8b"), walkTarGz},
+	{[]byte("BZh"), walkTarBz2},
+}
+
+// walkArchive calls fn for each entry of the archive. The format of the
+// archive is sniffed from its content.
+func walkArchive(file string, fn func(*entry) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	p := make([]byte, 4)
+	n, err := io.ReadFull(f, p)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return errFormat
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	for _, m := range magic {
+		if bytes.HasPrefix(p[:n], m.p) {
+			return m.walk(f, fn)
+		}
+	}
+	return errFormat
+}
+
+func walkZip(f *os.File, fn func(*entry) error) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	r, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		return err
+	}
+	for _, zf := range r.File {
+		e := &entry{name: zf.Name, mode: zf.Mode(), size: int64(zf.UncompressedSize64)}
+		if e.mode.IsDir() || strings.HasSuffix(zf.Name, "/") {
+			e.mode |= os.ModeDir
+			if err = fn(e); err != nil {
+				return err
+			}
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		if e.mode&os.ModeSymlink != 0 {
+			// Zip archives store a target of a symlink as its content.
+			var p []byte
+			p, err = ioutil.ReadAll(io.LimitReader(rc, 4096))
+			e.link = string(p)
+		} else {
+			e.r = rc
+		}
+		if err == nil {
+			err = fn(e)
+		}
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkTar(r io.Reader, fn func(*entry) error) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		e := &entry{
+			name: strings.TrimPrefix(hdr.Name, "./"),
+			mode: hdr.FileInfo().Mode(),
+			size: hdr.Size,
+			link: hdr.Linkname,
+			r:    tr,
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeReg, tar.TypeSymlink:
+		case tar.TypeXGlobalHeader:
+			continue
+		default:
+			return fmt.Errorf("unsupported entry %q", hdr.Name)
+		}
+		if e.name == "" {
+			continue
+		}
+		if err = fn(e); err != nil {
+			return err
+		}
+	}
+}
+
+func walkTarGz(f *os.File, fn func(*entry) error) error {
+	r, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return walkTar(r, fn)
+}
+
+func walkTarBz2(f *os.File, fn func(*entry) error) error {
+	return walkTar(bzip2.NewReader(bufio.NewReader(f)), fn)
+}
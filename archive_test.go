@@ -0,0 +1,284 @@
+package pkgconfig
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+)
+
+func newtargz(t *testing.T, files map[string]string, links map[string]string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name])), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+		if _, err := tw.Write([]byte(files[name])); err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+	}
+	for name, link := range links {
+		hdr := &tar.Header{Name: name, Mode: 0777, Linkname: link, Typeflag: tar.TypeSymlink}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	return buf.Bytes()
+}
+
+// newtargzhdr creates a tar.gz archive with the given entries, all of them
+// with empty content.
+func newtargzhdr(t *testing.T, hdrs ...*tar.Header) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, hdr := range hdrs {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	return buf.Bytes()
+}
+
+func walknames(file string) ([]string, error) {
+	var names []string
+	err := walkArchive(file, func(e *entry) error {
+		if !e.mode.IsDir() {
+			names = append(names, e.name)
+		}
+		return nil
+	})
+	sort.Strings(names)
+	return names, err
+}
+
+func TestWalkArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"include/libfoo/foo.h":             "#define FOO 1\n",
+		"lib/linux_amd64/libfoo/libfoo.pc": "Name: libfoo\n",
+	}
+	exp := []string{"include/libfoo/foo.h", "lib/linux_amd64/libfoo/libfoo.pc"}
+	cases := map[string][]byte{
+		"libfoo.zip":    newzip(t, files),
+		"libfoo.tar.gz": newtargz(t, files, nil),
+		// Format is sniffed from the content, not the extension.
+		"libfoo.bin": newtargz(t, files, nil),
+	}
+	for name, p := range cases {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), p, 0644); err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+	}
+	for _, file := range []string{
+		filepath.Join(dir, "libfoo.zip"),
+		filepath.Join(dir, "libfoo.tar.gz"),
+		filepath.Join(dir, "libfoo.bin"),
+		filepath.Join("testdata", "archive", "libfoo.tar.bz2"),
+	} {
+		names, err := walknames(file)
+		if err != nil {
+			t.Errorf("expected err=nil; was %q (file=%s)", err, file)
+			continue
+		}
+		if !reflect.DeepEqual(names, exp) {
+			t.Errorf("expected names=%v; was %v (file=%s)", exp, names, file)
+		}
+	}
+}
+
+func TestWalkArchiveErr(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	defer os.RemoveAll(dir)
+	cases := [...][]byte{
+		nil,
+		[]byte("PK"),
+		[]byte("<html>not an archive</html>"),
+		[]byte("\x1f\x8bnot a gzip stream"),
+		newtargzhdr(t, &tar.Header{Name: "lib/linux_amd64/libfoo/libfoo.so", Linkname: "libfoo.so.1",
+			Typeflag: tar.TypeLink}),
+	}
+	for i, cas := range cases {
+		file := filepath.Join(dir, "archive")
+		if err = ioutil.WriteFile(file, cas, 0644); err != nil {
+			t.Fatalf("expected err=nil; was %q (i=%d)", err, i)
+		}
+		if _, err = walknames(file); err == nil {
+			t.Errorf("expected err!=nil (i=%d)", i)
+		}
+	}
+}
+
+func TestLookupGithubTarGz(t *testing.T) {
+	dir, restore := tempgopath(t)
+	defer restore()
+	p := newtargz(t, map[string]string{
+		"include/libfoo/foo.h": "#define FOO 1\n",
+		"lib/" + target + "/libfoo/libfoo.pc": "libdir=${GOPATH}/lib/${GOOS}_${GOARCH}/libfoo\n\n" +
+			"Name: libfoo\nVersion: 1.0\nLibs: -L${libdir} -lfoo\n",
+	}, nil)
+	tgz := "/github.com/user/proj/releases/download/pkg-config/libfoo.tar.gz"
+	mux := http.NewServeMux()
+	mux.HandleFunc(tgz, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(p)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	f := &Fetcher{Client: srv.Client(), BaseURL: srv.URL + "/"}
+	e, err := f.Get("libfoo", "github.com/user/proj")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if e.URL != srv.URL+tgz {
+		t.Errorf("expected e.URL=%q; was %q", srv.URL+tgz, e.URL)
+	}
+	if err = existFile(filepath.Join(dir, "include", "libfoo", "foo.h")); err != nil {
+		t.Errorf("expected err=nil; was %q", err)
+	}
+}
+
+func TestInstallModesAndSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require elevated privileges on windows")
+	}
+	dir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	defer os.RemoveAll(dir)
+	lib := "lib/linux_amd64/libfoo/"
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, f := range []struct {
+		name    string
+		mode    os.FileMode
+		content string
+	}{
+		{lib + "libfoo.so.1.2", 0644, "ELF"},
+		{lib + "libfoo.so.1", os.ModeSymlink | 0777, "libfoo.so.1.2"},
+		{lib + "libfoo.so", os.ModeSymlink | 0777, "libfoo.so.1"},
+		{lib + "foo-config", 0777, "#!/bin/sh\n"},
+		{"include/libfoo/foo.h", 0, "#define FOO 1\n"},
+	} {
+		hdr := &zip.FileHeader{Name: f.name, Method: zip.Deflate}
+		if f.mode != 0 {
+			hdr.SetMode(f.mode)
+		}
+		fw, err := w.CreateHeader(hdr)
+		if err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+		fw.Write([]byte(f.content))
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	archives := map[string][]byte{
+		"libfoo.zip": buf.Bytes(),
+		"libfoo.tar.gz": newtargzhdr(t,
+			&tar.Header{Name: lib + "libfoo.so.1.2", Mode: 0644, Typeflag: tar.TypeReg},
+			&tar.Header{Name: lib + "libfoo.so.1", Linkname: "libfoo.so.1.2", Typeflag: tar.TypeSymlink},
+			&tar.Header{Name: lib + "libfoo.so", Linkname: "libfoo.so.1", Typeflag: tar.TypeSymlink},
+			&tar.Header{Name: lib + "foo-config", Mode: 0777, Typeflag: tar.TypeReg},
+			&tar.Header{Name: "include/libfoo/foo.h", Mode: 0644, Typeflag: tar.TypeReg},
+		),
+	}
+	for name, p := range archives {
+		root := filepath.Join(dir, name+".d")
+		file := filepath.Join(dir, name)
+		if err = ioutil.WriteFile(file, p, 0644); err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+		// Installing twice checks existing files and symlinks are replaced.
+		for i := 0; i < 2; i++ {
+			if err = install(root, file, "libfoo"); err != nil {
+				t.Fatalf("expected err=nil; was %q (archive=%s)", err, name)
+			}
+		}
+		libdir := filepath.Join(root, "lib", "linux_amd64", "libfoo")
+		if link, err := os.Readlink(filepath.Join(libdir, "libfoo.so")); err != nil || link != "libfoo.so.1" {
+			t.Errorf("expected libfoo.so -> libfoo.so.1; was %q (err=%v, archive=%s)", link, err, name)
+		}
+		if fi, err := os.Stat(filepath.Join(libdir, "libfoo.so")); err != nil || !fi.Mode().IsRegular() {
+			t.Errorf("expected libfoo.so to resolve to a file; was %v (err=%v, archive=%s)", fi, err, name)
+		}
+		fi, err := os.Stat(filepath.Join(libdir, "foo-config"))
+		if err != nil || fi.Mode().Perm()&0111 == 0 {
+			t.Errorf("expected foo-config to be executable; was %v (err=%v, archive=%s)", fi, err, name)
+		}
+		if err == nil && fi.Mode().Perm()&0022 != 0 {
+			t.Errorf("expected foo-config to not be writable by others; was %v (archive=%s)", fi, name)
+		}
+		fi, err = os.Stat(filepath.Join(root, "include", "libfoo", "foo.h"))
+		if err != nil || fi.Mode().Perm()&0111 != 0 {
+			t.Errorf("expected foo.h to not be executable; was %v (err=%v, archive=%s)", fi, err, name)
+		}
+	}
+}
+
+func TestInstallSymlinkEscape(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	defer os.RemoveAll(dir)
+	links := []string{
+		"/etc/passwd",
+		"../../../../../etc/passwd",
+		"../../../../..",
+		`..\..\..\..\..\x`,
+		"",
+	}
+	for i, link := range links {
+		file := filepath.Join(dir, "libfoo.tar.gz")
+		p := newtargzhdr(t, &tar.Header{Name: "lib/linux_amd64/libfoo/libfoo.so", Linkname: link,
+			Typeflag: tar.TypeSymlink})
+		if err = ioutil.WriteFile(file, p, 0644); err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+		if err = install(filepath.Join(dir, "root"), file, "libfoo"); err == nil {
+			t.Errorf("expected err!=nil (i=%d)", i)
+		}
+	}
+	for i, link := range []string{"libfoo.so.1", "../../linux_386/libfoo/libfoo.so", "../../../include/libfoo/foo.h"} {
+		if !validLink("lib/linux_amd64/libfoo/libfoo.so", link) {
+			t.Errorf("expected link=%q to be valid (i=%d)", link, i)
+		}
+	}
+}
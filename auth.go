@@ -0,0 +1,140 @@
+package pkgconfig
+
+import (
+	"bufio"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// hostTokens maps hosts to the environment variables commonly holding their
+// access tokens.
+var hostTokens = map[string]string{
+	"github.com": "GITHUB_TOKEN",
+	"gitlab.com": "GITLAB_TOKEN",
+}
+
+// EnvToken gives the access token for the given host read from
+// the PKG_CONFIG_TOKEN_HOST environment variable, where HOST is the host name
+// with dots and dashes replaced by underscores, for example
+// PKG_CONFIG_TOKEN_GIT_EXAMPLE_COM. For github.com and gitlab.com
+// the GITHUB_TOKEN and GITLAB_TOKEN variables are used as well.
+func EnvToken(host string) string {
+	env := "PKG_CONFIG_TOKEN_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(host))
+	if token := os.Getenv(env); token != "" {
+		return token
+	}
+	if env, ok := hostTokens[host]; ok {
+		return os.Getenv(env)
+	}
+	return ""
+}
+
+type netrcLogin struct {
+	login, password string
+}
+
+// DefaultNetrc gives the path of the netrc file, which is either set by
+// the NETRC environment variable or is the .netrc file in the home directory
+// (_netrc on Windows).
+func DefaultNetrc() string {
+	if file := os.Getenv("NETRC"); file != "" {
+		return file
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(home, "_netrc")
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// readNetrc reads the machine entries of the netrc file. The default entry
+// is ignored, as its credentials would be sent to any host.
+func readNetrc(file string) (map[string]netrcLogin, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var (
+		logins  = make(map[string]netrcLogin)
+		machine string
+		l       netrcLogin
+		buf     = bufio.NewScanner(f)
+	)
+	flush := func() {
+		if machine != "" {
+			if _, ok := logins[machine]; !ok {
+				logins[machine] = l
+			}
+		}
+		machine, l = "", netrcLogin{}
+	}
+	for buf.Scan() {
+		line := buf.Text()
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		v := strings.Fields(line)
+		for i := 0; i < len(v); i++ {
+			switch v[i] {
+			case "machine", "default":
+				flush()
+				if v[i] == "machine" && i+1 < len(v) {
+					i++
+					machine = v[i]
+				}
+			case "login", "password", "account":
+				if i+1 < len(v) {
+					i++
+					if v[i-1] == "login" {
+						l.login = v[i]
+					} else if v[i-1] == "password" {
+						l.password = v[i]
+					}
+				}
+			case "macdef":
+				// A macro definition spans until an empty line.
+				flush()
+				for buf.Scan() && strings.TrimSpace(buf.Text()) != "" {
+				}
+				i = len(v)
+			}
+		}
+	}
+	flush()
+	return logins, buf.Err()
+}
+
+// authTransport adds credentials to the requests made over https. The
+// credentials are chosen by the host of each request, so they're never
+// sent to another host when following a redirect.
+type authTransport struct {
+	base   http.RoundTripper
+	token  func(host string) string
+	logins map[string]netrcLogin
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" || req.Header.Get("Authorization") != "" {
+		return t.base.RoundTrip(req)
+	}
+	host := req.URL.Hostname()
+	if t.token != nil {
+		if token := t.token(host); token != "" {
+			req = req.Clone(req.Context())
+			req.Header.Set("Authorization", "Bearer "+token)
+			return t.base.RoundTrip(req)
+		}
+	}
+	if l, ok := t.logins[host]; ok {
+		req = req.Clone(req.Context())
+		req.SetBasicAuth(l.login, l.password)
+	}
+	return t.base.RoundTrip(req)
+}
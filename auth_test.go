@@ -0,0 +1,161 @@
+package pkgconfig
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// authServer serves libfoo.zip, redirecting to another host, and records
+// the Authorization headers received by each of the hosts.
+func authServer(t *testing.T, tls bool) (*httptest.Server, *http.Client, map[string]string) {
+	p := libfoozip(t)
+	var mu sync.Mutex
+	auth := make(map[string]string)
+	var srv *httptest.Server
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if r.URL.Path == libfooPath || r.URL.Path == "/archive/libfoo.zip" {
+			auth[r.Host] = r.Header.Get("Authorization")
+		}
+		mu.Unlock()
+		switch r.URL.Path {
+		case libfooPath:
+			scheme := "http"
+			if r.TLS != nil {
+				scheme = "https"
+			}
+			http.Redirect(w, r, scheme+"://example.com:"+port(srv)+"/archive/libfoo.zip", http.StatusFound)
+		case "/archive/libfoo.zip":
+			w.Write(p)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	if tls {
+		srv = httptest.NewTLSServer(handler)
+	} else {
+		srv = httptest.NewServer(handler)
+	}
+	// The example.com host is served by the same server.
+	tr := srv.Client().Transport.(*http.Transport).Clone()
+	tr.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+	}
+	return srv, &http.Client{Transport: tr}, auth
+}
+
+func TestFetcherAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	defer os.RemoveAll(dir)
+	netrc := filepath.Join(dir, "netrc")
+	err = ioutil.WriteFile(netrc, []byte("machine 127.0.0.1 login user password secret\n"+
+		"machine example.com login other password other\n"), 0600)
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	token := func(host string) string {
+		if host == "127.0.0.1" {
+			return "token"
+		}
+		return ""
+	}
+	cases := [...]struct {
+		tls   bool
+		token func(string) string
+		netrc string
+		exp   [2]string
+	}{
+		{true, token, "", [2]string{"Bearer token", ""}},
+		{true, nil, netrc, [2]string{"Basic dXNlcjpzZWNyZXQ=", "Basic b3RoZXI6b3RoZXI="}},
+		{true, token, netrc, [2]string{"Bearer token", "Basic b3RoZXI6b3RoZXI="}},
+		{true, nil, "", [2]string{"", ""}},
+		{false, token, netrc, [2]string{"", ""}},
+	}
+	for i, cas := range cases {
+		_, restore := tempgopath(t)
+		srv, client, auth := authServer(t, cas.tls)
+		f := &Fetcher{Client: client, BaseURL: srv.URL + "/", Token: cas.token, Netrc: cas.netrc}
+		if _, err := f.Get("libfoo", "github.com/user/proj"); err != nil {
+			t.Errorf("expected err=nil; was %q (i=%d)", err, i)
+		}
+		got := [2]string{auth[srv.Listener.Addr().String()], auth["example.com:"+port(srv)]}
+		if got != cas.exp {
+			t.Errorf("expected auth=%q; was %q (i=%d)", cas.exp, got, i)
+		}
+		srv.Close()
+		restore()
+	}
+}
+
+func port(srv *httptest.Server) string {
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	return port
+}
+
+func TestReadNetrc(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "netrc")
+	content := `# comment
+machine git.example.com
+	login user
+	password secret
+macdef init
+	machine evil.example.com login evil password evil
+
+machine api.example.com login token password x account y
+default login anonymous password anonymous
+`
+	if err = ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	logins, err := readNetrc(file)
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	exp := map[string]netrcLogin{
+		"git.example.com": {"user", "secret"},
+		"api.example.com": {"token", "x"},
+	}
+	if !reflect.DeepEqual(logins, exp) {
+		t.Errorf("expected logins=%v; was %v", exp, logins)
+	}
+}
+
+func TestEnvToken(t *testing.T) {
+	for _, env := range []string{"GITHUB_TOKEN", "PKG_CONFIG_TOKEN_GITHUB_COM", "PKG_CONFIG_TOKEN_GIT_EXAMPLE_COM"} {
+		defer os.Setenv(env, os.Getenv(env))
+		os.Unsetenv(env)
+	}
+	os.Setenv("GITHUB_TOKEN", "github")
+	os.Setenv("PKG_CONFIG_TOKEN_GIT_EXAMPLE_COM", "gitea")
+	cases := map[string]string{
+		"github.com":      "github",
+		"git.example.com": "gitea",
+		"git-example.com": "gitea",
+		"example.com":     "",
+	}
+	for host, exp := range cases {
+		if token := EnvToken(host); token != exp {
+			t.Errorf("expected token=%q; was %q (host=%s)", exp, token, host)
+		}
+	}
+	os.Setenv("PKG_CONFIG_TOKEN_GITHUB_COM", "override")
+	if token := EnvToken("github.com"); token != "override" {
+		t.Errorf("expected token=override; was %q", token)
+	}
+}
@@ -0,0 +1,190 @@
+package pkgconfig
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Cache is a content-addressed store of downloaded library archives, which
+// spares downloading the same archive again. The archives are stored under
+// their checksums, each of the URLs points to the checksum of the archive
+// it was last downloaded as.
+type Cache struct {
+	// Dir is the root directory of the cache.
+	Dir string
+}
+
+// CacheEntry describes a single cached archive.
+type CacheEntry struct {
+	URL     string
+	Sum     string
+	Size    int64
+	ModTime time.Time
+}
+
+func (e CacheEntry) String() string {
+	return fmt.Sprintf("%s %s %d", e.URL, e.Sum, e.Size)
+}
+
+// DefaultCacheDir gives the pkg-config directory within the user's cache
+// directory. It returns empty string, if the latter is not known.
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "pkg-config")
+}
+
+// blob gives the location of the archive with the given checksum or empty
+// string, if the checksum is malformed.
+func (c *Cache) blob(sum string) string {
+	if !validSum(sum) {
+		return ""
+	}
+	return filepath.Join(c.Dir, "archives", strings.TrimPrefix(sum, "sha256:"))
+}
+
+func (c *Cache) index(url string) string {
+	h := sha256.Sum256([]byte(url))
+	return filepath.Join(c.Dir, "urls", hex.EncodeToString(h[:]))
+}
+
+// readIndex reads an entry of the URL index, which has the "URL sha256:HEX"
+// format.
+func readIndex(file string) (url, sum string, err error) {
+	p, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", "", err
+	}
+	v := strings.Fields(string(p))
+	if len(v) != 2 || !validSum(v[1]) {
+		return "", "", fmt.Errorf("%s: malformed cache entry", file)
+	}
+	return v[0], v[1], nil
+}
+
+// lookup gives the cached archive with the given checksum or, if the checksum
+// is empty, the one last downloaded from the url. An archive which does not
+// match its checksum anymore is removed from the cache.
+func (c *Cache) lookup(url, sum string) (string, string, bool) {
+	if sum == "" {
+		var u string
+		if u, sum, _ = readIndex(c.index(url)); u != url {
+			return "", "", false
+		}
+	}
+	file := c.blob(sum)
+	if file == "" {
+		return "", "", false
+	}
+	if s, err := sha256file(file); err != nil || s != sum {
+		os.Remove(file)
+		return "", "", false
+	}
+	return file, sum, true
+}
+
+// writeFile atomically writes the content read from r to the file.
+func writeFile(file string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(file), ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, r)
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// put stores the archive downloaded from the url. The archive is not written
+// again if it is already stored, e.g. downloaded from another url.
+func (c *Cache) put(url, file, sum string) error {
+	blob := c.blob(sum)
+	if blob == "" {
+		return fmt.Errorf("malformed checksum %q", sum)
+	}
+	if s, err := sha256file(blob); err != nil || s != sum {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		err = writeFile(blob, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return writeFile(c.index(url), strings.NewReader(url+" "+sum+"\n"))
+}
+
+// sig gives the cached signature of the archive with the given checksum.
+func (c *Cache) sig(sum string) []byte {
+	blob := c.blob(sum)
+	if blob == "" {
+		return nil
+	}
+	p, err := ioutil.ReadFile(blob + ".sig")
+	if err != nil {
+		return nil
+	}
+	return p
+}
+
+func (c *Cache) putSig(sum string, p []byte) error {
+	blob := c.blob(sum)
+	if blob == "" {
+		return fmt.Errorf("malformed checksum %q", sum)
+	}
+	return writeFile(blob+".sig", bytes.NewReader(p))
+}
+
+// List gives all the cached archives sorted by their URLs. Archives which
+// were removed or are no longer pointed to by any URL are not listed.
+func (c *Cache) List() ([]CacheEntry, error) {
+	fis, err := ioutil.ReadDir(filepath.Join(c.Dir, "urls"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []CacheEntry
+	for _, fi := range fis {
+		url, sum, err := readIndex(filepath.Join(c.Dir, "urls", fi.Name()))
+		if err != nil {
+			continue
+		}
+		bi, err := os.Stat(c.blob(sum))
+		if err != nil {
+			continue
+		}
+		entries = append(entries, CacheEntry{URL: url, Sum: sum, Size: bi.Size(), ModTime: bi.ModTime()})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].URL < entries[j].URL })
+	return entries, nil
+}
+
+// Clean removes all the cached archives.
+func (c *Cache) Clean() error {
+	return os.RemoveAll(c.Dir)
+}
@@ -0,0 +1,114 @@
+package pkgconfig
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFetcherCache(t *testing.T) {
+	dir, restore := tempgopath(t)
+	defer restore()
+	p := libfoozip(t)
+	var n int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != libfooPath {
+			http.NotFound(w, r)
+			return
+		}
+		n++
+		w.Write(p)
+	}))
+	defer srv.Close()
+	cachedir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	defer os.RemoveAll(cachedir)
+	c := &Cache{Dir: cachedir}
+	offline := &Fetcher{Client: srv.Client(), BaseURL: srv.URL + "/", Cache: c, Offline: true}
+	if _, err = offline.Get("libfoo", "github.com/user/proj"); err == nil {
+		t.Fatal("expected err!=nil")
+	}
+	f := &Fetcher{Client: srv.Client(), BaseURL: srv.URL + "/", Cache: c}
+	e, err := f.Get("libfoo", "github.com/user/proj")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	entries, err := c.List()
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if len(entries) != 1 || entries[0].URL != e.URL || entries[0].Sum != e.Sum ||
+		entries[0].Size != int64(len(p)) {
+		t.Errorf("expected one entry for %s %s; was %v", e.URL, e.Sum, entries)
+	}
+	sumfile := filepath.Join(cachedir, "cdeps.sum")
+	if err = appendSum(sumfile, "github.com/user/proj", "libfoo", e.Sum); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	sum := &Fetcher{Client: srv.Client(), BaseURL: srv.URL + "/", Cache: c, SumFile: sumfile}
+	// Online, the archive is downloaded again unless its checksum is known.
+	for i, f := range []*Fetcher{f, sum, offline} {
+		if err = os.RemoveAll(filepath.Join(dir, "include")); err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+		if _, err = f.Get("libfoo", "github.com/user/proj"); err != nil {
+			t.Fatalf("expected err=nil; was %q (i=%d)", err, i)
+		}
+		if err = existFile(filepath.Join(dir, "include", "libfoo", "foo.h")); err != nil {
+			t.Errorf("expected err=nil; was %q (i=%d)", err, i)
+		}
+	}
+	if n != 2 {
+		t.Errorf("expected the archive to be downloaded twice; was %d times", n)
+	}
+	if entries, err = c.List(); err != nil || len(entries) != 1 {
+		t.Errorf("expected one entry; was %v (err=%v)", entries, err)
+	}
+	// A corrupted archive is not used.
+	if err = ioutil.WriteFile(c.blob(e.Sum), []byte("corrupted"), 0644); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if _, err = offline.Get("libfoo", "github.com/user/proj"); err == nil {
+		t.Error("expected err!=nil")
+	}
+	// A changed archive is not shadowed by the cached one.
+	p = newzip(t, map[string]string{"include/libfoo/foo.h": "v2"})
+	if _, err = f.Get("libfoo", "github.com/user/proj"); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if q, err := ioutil.ReadFile(filepath.Join(dir, "include", "libfoo", "foo.h")); err != nil || string(q) != "v2" {
+		t.Errorf("expected foo.h=v2; was %q (err=%v)", q, err)
+	}
+	if err = c.Clean(); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if entries, err = c.List(); err != nil || len(entries) != 0 {
+		t.Errorf("expected empty cache; was %v (err=%v)", entries, err)
+	}
+}
+
+func TestCacheMalformedSum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	defer os.RemoveAll(dir)
+	victim := filepath.Join(dir, "victim")
+	if err = ioutil.WriteFile(victim, []byte("victim"), 0644); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	f := &Fetcher{Cache: &Cache{Dir: filepath.Join(dir, "cache")}, Offline: true}
+	e := &LockEntry{Lib: "libfoo", Proj: "github.com/user/proj", URL: "https://example.com/libfoo.zip",
+		Sum: "sha256:../../victim"}
+	if _, _, ok := f.cached(e); ok {
+		t.Error("expected ok=false")
+	}
+	if err = existFile(victim); err != nil {
+		t.Errorf("expected the file outside of the cache to be kept; was %q", err)
+	}
+}
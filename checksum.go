@@ -0,0 +1,214 @@
+package pkgconfig
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SumFileName is the name of a go.sum-like file, committed in a consuming
+// repository, which holds checksums of library archives. Each line has
+// the following format:
+//
+//	github.com/USER/PROJECT LIBRARY sha256:HEX
+const SumFileName = "cdeps.sum"
+
+// ChecksumError is returned when a downloaded archive does not match
+// its expected checksum.
+type ChecksumError struct {
+	URL      string
+	Source   string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch for %s: %s expects %s, downloaded %s",
+		e.URL, e.Source, e.Expected, e.Actual)
+}
+
+func sha256file(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// validSum tells whether the checksum is "sha256:" followed by the lowercase
+// hex digest, as sha256file gives it.
+func validSum(sum string) bool {
+	hexsum := strings.TrimPrefix(sum, "sha256:")
+	if len(hexsum) != 2*sha256.Size || hexsum == sum {
+		return false
+	}
+	for i := 0; i < len(hexsum); i++ {
+		if c := hexsum[i]; (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// findFile looks up the named file in the given directory and all its
+// parents. If none exists, it gives the path within the root directory.
+func findFile(dir, root, name string) string {
+	for d := dir; ; {
+		file := filepath.Join(d, name)
+		if existFile(file) == nil {
+			return file
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+	return filepath.Join(root, name)
+}
+
+func sumkey(proj, pkg string) string {
+	return proj + " " + pkg
+}
+
+func readSums(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var (
+		sums = make(map[string]string)
+		buf  = bufio.NewScanner(f)
+	)
+	for n := 1; buf.Scan(); n++ {
+		v := strings.Fields(buf.Text())
+		if len(v) == 0 {
+			continue
+		}
+		if len(v) != 3 || !validSum(v[2]) {
+			return nil, fmt.Errorf("%s:%d: malformed line", file, n)
+		}
+		sums[sumkey(v[0], v[1])] = v[2]
+	}
+	return sums, buf.Err()
+}
+
+func appendSum(file, proj, pkg, sum string) error {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "%s %s %s\n", proj, pkg, sum)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// siblingSum fetches the LIBRARY.zip.sha256 asset published next to
+// the archive. It returns empty string if there's no such asset.
+func (f *Fetcher) siblingSum(ctx context.Context, url string) (sum string, err error) {
+	err = f.retry(ctx, func() error {
+		sum, err = f.readSum(ctx, url)
+		return err
+	})
+	return sum, err
+}
+
+func (f *Fetcher) readSum(ctx context.Context, url string) (string, error) {
+	res, err := f.httpGet(ctx, url+".sha256")
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", nil
+	default:
+		return "", newStatusError(url+".sha256", res)
+	}
+	p, err := ioutil.ReadAll(io.LimitReader(res.Body, 4096))
+	if err != nil {
+		return "", err
+	}
+	// The asset is either a bare hex digest or a sha256sum output.
+	v := strings.Fields(string(p))
+	if len(v) == 0 {
+		return "", errors.New("empty checksum file: " + url + ".sha256")
+	}
+	if _, err = hex.DecodeString(v[0]); err != nil || len(v[0]) != 2*sha256.Size {
+		return "", errors.New("malformed checksum file: " + url + ".sha256")
+	}
+	return "sha256:" + strings.ToLower(v[0]), nil
+}
+
+// verify checks the downloaded file against the sibling checksum asset
+// and the sum file. It gives the checksum of the file and whether it should
+// be recorded in the sum file, which is the case if the file has no entry
+// for the archive yet.
+func (f *Fetcher) verify(ctx context.Context, file, url, pkg, proj string) (sum string, record bool, err error) {
+	if sum, err = sha256file(file); err != nil {
+		return
+	}
+	sibling, err := f.siblingSum(ctx, url)
+	if err != nil {
+		return
+	}
+	if sibling != "" && sibling != sum {
+		err = &ChecksumError{URL: url, Source: url + ".sha256", Expected: sibling, Actual: sum}
+		return
+	}
+	record, err = f.verifySumFile(sum, url, pkg, proj)
+	return
+}
+
+// verifySumFile checks the checksum against the sum file only. It reports
+// whether the checksum should be recorded.
+func (f *Fetcher) verifySumFile(sum, url, pkg, proj string) (bool, error) {
+	if f.SumFile == "" {
+		return false, nil
+	}
+	sums, err := readSums(f.SumFile)
+	if err != nil {
+		return false, err
+	}
+	expected, ok := sums[sumkey(proj, pkg)]
+	if ok && expected != sum {
+		return false, &ChecksumError{URL: url, Source: f.SumFile, Expected: expected, Actual: sum}
+	}
+	return !ok, nil
+}
+
+// projroot gives the directory of the given project, which the dir is part of.
+func projroot(dir, proj string) string {
+	for d := dir; proj != ""; {
+		if strings.HasSuffix(filepath.ToSlash(d), "/"+proj) {
+			return d
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+	return dir
+}
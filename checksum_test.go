@@ -0,0 +1,182 @@
+package pkgconfig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newLibfooServer(t *testing.T, p []byte, sha string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(libfooPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(p)
+	})
+	if sha != "" {
+		mux.HandleFunc(libfooPath+".sha256", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(sha + "  libfoo.zip\n"))
+		})
+	}
+	return httptest.NewServer(mux)
+}
+
+func TestFetcherChecksumSibling(t *testing.T) {
+	dir, restore := tempgopath(t)
+	defer restore()
+	p := libfoozip(t)
+	sum := sha256.Sum256(p)
+	cases := [...]struct {
+		sha string
+		ok  bool
+	}{
+		{hex.EncodeToString(sum[:]), true},
+		{strings.ToUpper(hex.EncodeToString(sum[:])), true},
+		{strings.Repeat("0", 64), false},
+		{"xyz", false},
+	}
+	for i, cas := range cases {
+		os.RemoveAll(filepath.Join(dir, "include"))
+		srv := newLibfooServer(t, p, cas.sha)
+		f := &Fetcher{Client: srv.Client(), BaseURL: srv.URL + "/"}
+		_, err := f.Lookup("libfoo", "github.com/user/proj")
+		srv.Close()
+		if cas.ok && err != nil {
+			t.Errorf("expected err=nil; was %q (i=%d)", err, i)
+		}
+		if !cas.ok {
+			if err == nil {
+				t.Errorf("expected err!=nil (i=%d)", i)
+			}
+			if existDir(filepath.Join(dir, "include")) == nil {
+				t.Errorf("expected nothing to be installed (i=%d)", i)
+			}
+		}
+	}
+}
+
+func TestFetcherChecksumSumFile(t *testing.T) {
+	dir, restore := tempgopath(t)
+	defer restore()
+	p := libfoozip(t)
+	srv := newLibfooServer(t, p, "")
+	defer srv.Close()
+	f := &Fetcher{
+		Client:  srv.Client(),
+		BaseURL: srv.URL + "/",
+		SumFile: filepath.Join(dir, SumFileName),
+	}
+	// First fetch records the checksum.
+	if _, err := f.Lookup("libfoo", "github.com/user/proj"); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	sum, err := sha256file(filepath.Join(dir, "include", "libfoo", "foo.h"))
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	sums, err := readSums(f.SumFile)
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	h := sha256.Sum256(p)
+	if exp := "sha256:" + hex.EncodeToString(h[:]); sums[sumkey("github.com/user/proj", "libfoo")] != exp {
+		t.Errorf("expected sum=%q; was %q", exp, sums)
+	}
+	// Second fetch verifies it and does not record it again.
+	if _, err = f.Lookup("libfoo", "github.com/user/proj"); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	raw, err := ioutil.ReadFile(f.SumFile)
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if n := strings.Count(string(raw), "\n"); n != 1 {
+		t.Errorf("expected 1 line; was %d", n)
+	}
+	// Tampered archive is refused.
+	if err = ioutil.WriteFile(f.SumFile, []byte("github.com/user/proj libfoo "+sum+"\n"), 0644); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	os.RemoveAll(filepath.Join(dir, "include"))
+	_, err = f.Lookup("libfoo", "github.com/user/proj")
+	if _, ok := err.(*ChecksumError); !ok {
+		t.Errorf("expected err to be *ChecksumError; was %v", err)
+	}
+	if existDir(filepath.Join(dir, "include")) == nil {
+		t.Errorf("expected nothing to be installed")
+	}
+}
+
+func TestReadSumsErr(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, SumFileName)
+	cases := [...]string{
+		"github.com/user/proj libfoo\n",
+		"github.com/user/proj libfoo md5:abc\n",
+		"github.com/user/proj libfoo sha256:abc extra\n",
+		"github.com/user/proj libfoo sha256:../../../x\n",
+		"github.com/user/proj libfoo sha256:" + strings.Repeat("AB", 32) + "\n",
+	}
+	for i, cas := range cases {
+		if err := ioutil.WriteFile(file, []byte(cas), 0644); err != nil {
+			t.Fatalf("expected err=nil; was %q (i=%d)", err, i)
+		}
+		if _, err := readSums(file); err == nil {
+			t.Errorf("expected err!=nil (i=%d)", i)
+		}
+	}
+}
+
+func TestValidSum(t *testing.T) {
+	cases := [...]struct {
+		sum string
+		ok  bool
+	}{
+		{"sha256:" + strings.Repeat("0a", 32), true},
+		{strings.Repeat("0a", 32), false},
+		{"sha256:" + strings.Repeat("0A", 32), false},
+		{"sha256:" + strings.Repeat("0a", 31), false},
+		{"sha256:" + strings.Repeat("0a", 33), false},
+		{"sha256:../../" + strings.Repeat("0a", 29), false},
+		{"sha256:", false},
+	}
+	for i, cas := range cases {
+		if ok := validSum(cas.sum); ok != cas.ok {
+			t.Errorf("expected ok=%t; was %t (i=%d)", cas.ok, ok, i)
+		}
+	}
+}
+
+func TestFindSumFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "src", "github.com", "user", "proj")
+	sub := filepath.Join(root, "cmd", "proj")
+	if err = os.MkdirAll(sub, 0755); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if r := projroot(sub, "github.com/user/proj"); r != root {
+		t.Errorf("expected r=%q; was %q", root, r)
+	}
+	if file := findFile(sub, root, SumFileName); file != filepath.Join(root, SumFileName) {
+		t.Errorf("expected file=%q; was %q", filepath.Join(root, SumFileName), file)
+	}
+	exp := filepath.Join(dir, "src", SumFileName)
+	if err = ioutil.WriteFile(exp, nil, 0644); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if file := findFile(sub, root, SumFileName); file != exp {
+		t.Errorf("expected file=%q; was %q", exp, file)
+	}
+}
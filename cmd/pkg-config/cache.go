@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/rjeczalik/pkgconfig"
+)
+
+func cache(args []string) {
+	if len(args) != 1 {
+		die(usage)
+	}
+	c := pkgconfig.DefaultFetcher.Cache
+	if c == nil {
+		die("unable to determine the cache directory")
+	}
+	switch args[0] {
+	case "list":
+		entries, err := c.List()
+		if err != nil {
+			die(err)
+		}
+		for _, e := range entries {
+			fmt.Println(e)
+		}
+	case "clean":
+		if err := c.Clean(); err != nil {
+			die(err)
+		}
+	default:
+		die(usage)
+	}
+}
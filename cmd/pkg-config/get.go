@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/rjeczalik/pkgconfig"
+)
+
+func get(args []string) {
+	var (
+		fs  = flag.NewFlagSet("get", flag.ExitOnError)
+		dry = fs.Bool("n", false, "print the plan of what would be fetched, without installing anything")
+	)
+	rootFlag(fs)
+	timeoutFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		die(usage)
+	}
+	ctx, cancel := interruptible()
+	defer cancel()
+	steps, err := pkgconfig.DefaultFetcher.GetAllContext(ctx, fs.Arg(1), fs.Arg(0), *dry)
+	if *dry {
+		for _, s := range steps {
+			fmt.Println(s)
+		}
+	}
+	if err != nil {
+		die(err)
+	}
+	if *dry {
+		return
+	}
+	lock, err := pkgconfig.ReadLockFile(pkgconfig.DefaultLockFile())
+	if err != nil {
+		die(err)
+	}
+	// Already installed packages are locked as well, so the lock file
+	// reproduces all of them.
+	for _, s := range steps {
+		if !s.Installed {
+			fmt.Fprintf(os.Stderr, "installed %s into %s\n", s.Lib, s.Root)
+		}
+	}
+	for _, s := range steps {
+		if s.URL == "" || s.Sum == "" {
+			die(s.Lib + " is installed in " + s.Root + " without a manifest, unable to lock it")
+		}
+		lock.Set(s.LockEntry)
+	}
+	if err = lock.Write(); err != nil {
+		die(err)
+	}
+}
+
+func syncLock(args []string) {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	rootFlag(fs)
+	timeoutFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		die(usage)
+	}
+	lock, err := pkgconfig.ReadLockFile(pkgconfig.DefaultLockFile())
+	if err != nil {
+		die(err)
+	}
+	ctx, cancel := interruptible()
+	defer cancel()
+	if err = pkgconfig.DefaultFetcher.SyncContext(ctx, lock); err != nil {
+		die(err)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/rjeczalik/pkgconfig"
+)
+
+func lint(args []string) {
+	if len(args) == 0 {
+		die(usage)
+	}
+	var found bool
+	for _, file := range args {
+		diag, err := pkgconfig.LintFile(file)
+		if err != nil {
+			die(err)
+		}
+		for _, d := range diag {
+			fmt.Println(d)
+		}
+		found = found || len(diag) != 0
+	}
+	if found {
+		os.Exit(1)
+	}
+}
+
+func format(args []string) {
+	var (
+		fs    = flag.NewFlagSet("fmt", flag.ExitOnError)
+		write = fs.Bool("w", false, "write result to the source file instead of stdout")
+		list  = fs.Bool("l", false, "list files whose formatting differs")
+	)
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		die(usage)
+	}
+	var differ bool
+	for _, file := range fs.Args() {
+		p, err := ioutil.ReadFile(file)
+		if err != nil {
+			die(err)
+		}
+		f, err := pkgconfig.ParsePCFile(bytes.NewReader(p))
+		if err != nil {
+			die(file + ": " + err.Error())
+		}
+		f.Format()
+		var buf bytes.Buffer
+		f.WriteTo(&buf)
+		if bytes.Equal(p, buf.Bytes()) {
+			if !*write && !*list {
+				os.Stdout.Write(p)
+			}
+			continue
+		}
+		differ = true
+		switch {
+		case *list:
+			fmt.Println(file)
+		case *write:
+			if err = ioutil.WriteFile(file, buf.Bytes(), 0644); err != nil {
+				die(err)
+			}
+		default:
+			os.Stdout.Write(buf.Bytes())
+		}
+	}
+	if differ && *list {
+		os.Exit(1)
+	}
+}
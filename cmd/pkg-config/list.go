@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/rjeczalik/pkgconfig"
+)
+
+// rootFlag adds the -root flag, which sets the workspace libraries are
+// installed into.
+func rootFlag(fs *flag.FlagSet) {
+	fs.StringVar(&pkgconfig.DefaultFetcher.Root, "root", "",
+		"workspace to install libraries into; $"+pkgconfig.InstallRootEnv+" or the first lookup one by default")
+}
+
+func installRoot() string {
+	root, err := pkgconfig.DefaultFetcher.InstallRoot()
+	if err != nil {
+		die(err)
+	}
+	return root
+}
+
+func list(args []string) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	rootFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		die(usage)
+	}
+	manifests, err := pkgconfig.ListInstalled(installRoot())
+	if err != nil {
+		die(err)
+	}
+	for _, m := range manifests {
+		version := m.Version()
+		if version == "" {
+			version = "-"
+		}
+		fmt.Println(m.Lib, version, strings.Join(m.Targets(), ","), m.Proj, m.URL)
+	}
+}
+
+func remove(args []string) {
+	fs := flag.NewFlagSet("remove", flag.ExitOnError)
+	rootFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		die(usage)
+	}
+	root := installRoot()
+	for _, lib := range fs.Args() {
+		if err := pkgconfig.Uninstall(root, lib); err != nil {
+			die(err)
+		}
+	}
+}
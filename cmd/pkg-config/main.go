@@ -6,8 +6,7 @@
 // To use cmd/pkg-config go install it and ensure it's in the PATH. A NOTE for
 // Linux users: the cmd/pkg-config must be present before original
 // /usr/bin/pkg-config in the PATH list; it is not advised to replace it,
-// as cmd/pkg-config is not a full replacement for the pkg-config tool, e.g.
-// it does not implement conflict and dependency resolving.
+// as cmd/pkg-config is not a full replacement for the pkg-config tool.
 //
 // The cgo tool uses pkg-config for obtaining CFLAGS and LDFLAGS of C libraries.
 // Example:
@@ -99,15 +98,24 @@
 // a production use, cmd/pkg-config can download a zip archive from project's
 // github.com releases for a pkg-config tag and unpack it into $GOPATH.
 // For example in order to make the above github.com/joe/png-wrapper package
-// pkg-config-gettable, it's enough to zip include/ and lib/ directories:
+// pkg-config-gettable, it's enough to pack its include/ and lib/ directories:
 //
-//   $ zip -9 -r libpng.zip include dir
+//   $ pkg-config pack libpng
+//
+// The pack subcommand writes the libpng.zip archive and its libpng.zip.sha256
+// checksum. It checks the files with the same rules they are installed with,
+// replaces absolute $GOPATH paths within the .pc files with ${GOPATH} and
+// produces the same archive from the same files each time. The -target flag
+// limits the archive to the given GOOS_GOARCH targets.
+//
+// Instead of a zip archive, a libpng.tar.gz, libpng.tgz or libpng.tar.bz2 tarball
+// can be attached as well; the formats are tried in that order.
 //
 // Create release, name a tag after pkg-config and attach libpng.zip do the
 // file list. This would make the libpng.zip archive be accessible from the following
 // link:
 //
-//   $ wget http://github.com/joe/png-wrapper/releases/download/pkg-config/libpng.zip
+//   $ wget https://github.com/joe/png-wrapper/releases/download/pkg-config/libpng.zip
 //
 // Which is the default location the cmd/pkg-config searches for libraries. Then
 // go-getting a joe/png-wrapper package altogether with C dependencies is as
@@ -115,13 +123,163 @@
 //
 //   $ go get github.com/joe/png-wrapper
 //
+// Projects hosted on gitlab.com, gitea.com and codeberg.org are supported out
+// of the box. Other hosts, like a private Gitea instance or an artifact mirror,
+// are registered in a cdeps.hosts file next to the cdeps.sum one, each mapping
+// a project path prefix to the gitea, gitlab or github layout or to a URL
+// template with {proj}, {tag}, {lib} and {ext} placeholders:
+//
+//   git.example.com gitea
+//   example.com/cdeps https://artifacts.example.com/{proj}/{tag}/{lib}{ext}
+//
+// Archives can be downloaded through mirrors as well, listed in order in
+// the PKG_CONFIG_GO_PROXY environment variable like module proxies are in
+// GOPROXY. A mirror serves the archives of each project under PROJ/TAG, e.g.
+// libpng.zip of the above project under github.com/joe/png-wrapper/pkg-config,
+// along with their checksum and signature assets. The next mirror is tried
+// only if the archive is not found; the "direct" keyword stands for
+// the project's host and "off" disallows downloading. The serve subcommand
+// serves a local directory of archives in that layout:
+//
+//   $ mkdir -p /srv/cdeps/github.com/joe/png-wrapper/pkg-config
+//   $ pkg-config pack -o /srv/cdeps/github.com/joe/png-wrapper/pkg-config libpng
+//   $ pkg-config serve -addr :8080 /srv/cdeps
+//   $ PKG_CONFIG_GO_PROXY=http://cdeps.example.com:8080,direct go build
+//
+// Archives of private projects are downloaded with credentials. A bearer
+// token is read from the PKG_CONFIG_TOKEN_HOST environment variable, e.g.
+// PKG_CONFIG_TOKEN_GIT_EXAMPLE_COM, or from GITHUB_TOKEN and GITLAB_TOKEN
+// for github.com and gitlab.com respectively. Hosts with no token use
+// the login and password from the ~/.netrc file. Credentials are sent over
+// https only, and only to the host they were configured for, so they never
+// leak to another host an archive download is redirected to.
+//
+// A project can publish more than one build of a library, each under its own
+// release tag. Consumers pin a version by appending the tag to the library name,
+// both when fetching and in the cgo directive:
+//
+//   $ pkg-config get github.com/joe/png-wrapper libpng@v1.2.46
+//
+//   // #cgo pkg-config: libpng@v1.2.46
+//
+// A tagged library is unpacked into its own $GOPATH/pkg/pkg-config/libpng@v1.2.46
+// directory, which has the same include and lib layout as $GOPATH itself, so
+// fetching another version never overwrites the one in use and upgrading is
+// always explicit. Library names without a tag keep using the pkg-config tag
+// and the $GOPATH/include and $GOPATH/lib directories.
+//
+// An archive can ship a lib/$GOOS_$GOARCH/libpng/libpng.deps file, which maps
+// the packages listed in Requires and Requires.private of the library to
+// the projects serving them:
+//
+//   zlib github.com/joe/zlib
+//
+// Requirements missing from $GOPATH, which are listed there, are fetched
+// recursively along with the library, each one at most once. With -n the get
+// subcommand only prints the plan of what would be fetched:
+//
+//   $ pkg-config get -n github.com/joe/png-wrapper libpng
+//   libpng github.com/joe/png-wrapper https://github.com/joe/png-wrapper/releases/download/pkg-config/libpng.zip
+//   zlib github.com/joe/zlib https://github.com/joe/zlib/releases/download/pkg-config/zlib.zip (required by libpng)
+//
+// Each library fetched with the get subcommand is recorded in a cdeps.lock file
+// in the root of the current module (the nearest directory with a go.mod file),
+// together with its source project, release tag, archive URL and checksum.
+// The sync subcommand installs exactly the locked set of libraries and fails
+// if any of the archives changed since it was locked:
+//
+//   $ pkg-config sync
+//
+// Libraries are installed into the first workspace they are looked up in:
+// the one the current directory is within or else the first $GOPATH one.
+// Another workspace is chosen with the -root flag or by exporting
+// PKG_CONFIG_INSTALL_ROOT; the latter also puts it first in the lookup order.
+// The get subcommand reports where each library was installed.
+//
+// Each installed library has its files recorded in an install manifest within
+// the $GOPATH/pkg/pkg-config directory. The list subcommand prints the installed
+// libraries together with their versions, targets and origin; the remove
+// subcommand deletes exactly the recorded files of a library and prunes
+// the directories left empty:
+//
+//   $ pkg-config list
+//   libpng 1.2.46 linux_amd64,windows_amd64 github.com/joe/png-wrapper https://github.com/joe/png-wrapper/releases/download/pkg-config/libpng.zip
+//   $ pkg-config remove libpng
+//
+// Downloaded archives are kept in a cache within the user's cache directory,
+// for example ~/.cache/pkg-config on Linux, stored under their checksums, so
+// an archive whose checksum is known from the lock file or cdeps.sum is not
+// downloaded again. With PKG_CONFIG_OFFLINE=1
+// exported nothing is downloaded and libraries are installed from the cache
+// only. The cache subcommand lists the cached archives or removes them all:
+//
+//   $ pkg-config cache list
+//   $ pkg-config cache clean
+//
+// By default HTTP requests are not limited in time. The -timeout flag of get
+// and sync, the --timeout=DURATION flag and the PKG_CONFIG_TIMEOUT environment
+// variable, which is also honored when the tool is run by cgo, limit each
+// request; an interrupt stops the downloads in progress and cleans them up:
+//
+//   $ PKG_CONFIG_TIMEOUT=30s go build
+//   $ pkg-config get -timeout 1m github.com/joe/png-wrapper libpng
+//
+// Requests which fail with a transient error, like a dropped connection or
+// a 503 response, are retried a few times with exponential backoff. Archive
+// downloads are resumed from where they stopped, if the server supports range
+// requests, also by the next run once the retries are exhausted. Progress of
+// the downloads is reported when the standard error is a terminal.
+//
+// Downloaded archives are verified before they are unpacked. If the release
+// contains a libpng.zip.sha256 asset, the archive must match it. In addition
+// the checksum of each archive is recorded in a cdeps.sum file in the root
+// of the consuming project, which should be committed alongside go.sum;
+// later downloads which do not match the recorded checksum are refused.
+//
+// Archives can be signed by their publishers as well. A publisher generates
+// a key pair once and signs each archive, which produces the libpng.zip.sig
+// file to be attached to the release next to the archive:
+//
+//   $ pkg-config sign -genkey ~/.pkg-config.key
+//   ed25519:Gb9KAVVxr0cBUXVbxEGMmgmKm7KL7u8yd0fTh2Z2vEw=
+//   $ pkg-config sign -key ~/.pkg-config.key libpng.zip
+//
+// Consumers list the trusted public keys per project in a cdeps.keys file next
+// to the cdeps.sum one. Archives of a project with trusted keys must carry
+// a valid signature made by one of them:
+//
+//   github.com/joe/png-wrapper ed25519:Gb9KAVVxr0cBUXVbxEGMmgmKm7KL7u8yd0fTh2Z2vEw=
+//
+// ** Linting and formatting .pc files **
+//
+// Handwritten .pc files can be checked for common mistakes, like hard-coded
+// absolute paths instead of ${GOPATH}, missing Version, duplicated keywords,
+// unused variables or -l flags in Cflags:
+//
+//   $ pkg-config lint $GOPATH/lib/linux_amd64/libpng/libpng.pc
+//
+// The fmt subcommand canonicalizes spacing and order of keywords; with -l it
+// lists the files which need formatting, with -w it rewrites them in place.
+// Both lint and fmt -l exit with nonzero status if they find anything.
+//
+// ** Validating libraries **
+//
+// The --validate flag checks a library together with its whole dependency tree:
+// every .pc file must parse, all Requires must resolve, each -I and -L directory
+// must exist and each -lNAME flag must have a matching library file for the
+// current target. All the problems found are printed and the tool exits with
+// nonzero status, so CI can verify a $GOPATH library tree before running go build:
+//
+//   $ pkg-config --validate libpng
+//
 // Default behavior of cmd/pkg-config
 //
 // The cmd/pkg-config tool looks up a .pc file for a $LIBRARY in the following order:
 //
-//   - $GOPATH/lib/$GOOS_$GOARCH/$LIBRARY/$LIBRARY.pc
+//   - $WORKSPACE/lib/$GOOS_$GOARCH/$LIBRARY/$LIBRARY.pc for each workspace:
+//     $PKG_CONFIG_INSTALL_ROOT, the one of the current directory and $GOPATH ones
 //   - if PKG_CONFIG_GITHUB=1 is exported, cmd/pkg-config tries to fetch library from
-//     http://github.com/$USER/$PROJECT/releases/download/pkg-config/$LIBRARY.zip
+//     https://github.com/$USER/$PROJECT/releases/download/pkg-config/$LIBRARY.zip
 //   - $PKG_CONFIG_PATH and eventual pkg-config's default search locations (platform-specific)
 //   - if no .pc file is found, pkg-config does its best to generate needed
 //     flags on-the-fly, assuming needed library files and headers are present
@@ -129,6 +287,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -139,10 +298,27 @@ const usage = `NAME:
 	pkg-config - Go-centric pkg-config replacement
 
 USAGE:
-	pkg-config --libs LIB
+	pkg-config [--timeout=DURATION] --libs LIB
 	pkg-config --cflags LIB
 	pkg-config --cflags --libs LIB1 LIB2
-	pkg-config get github.com/USER/PROJECT LIB`
+	pkg-config --cflags "LIB >= VERSION"
+	pkg-config --static --cflags --libs LIB
+	pkg-config --validate [--static] LIB
+	pkg-config --print-provides LIB
+	pkg-config --print-requires LIB
+	pkg-config --print-requires-private LIB
+	pkg-config get [-n] [-root DIR] [-timeout DURATION] HOST/USER/PROJECT LIB[@TAG]
+	pkg-config sync [-root DIR] [-timeout DURATION]
+	pkg-config list [-root DIR]
+	pkg-config remove [-root DIR] LIB...
+	pkg-config cache list
+	pkg-config cache clean
+	pkg-config lint FILE...
+	pkg-config fmt [-l] [-w] FILE...
+	pkg-config sign -genkey KEYFILE
+	pkg-config sign -key KEYFILE ARCHIVE...
+	pkg-config pack [-o DIR] [-gopath DIR] [-target GOOS_GOARCH,...] LIB...
+	pkg-config serve [-addr ADDR] DIR`
 
 func die(v ...interface{}) {
 	for _, v := range v {
@@ -155,22 +331,64 @@ func ishelp(s string) bool {
 	return s == "-h" || s == "-help" || s == "help" || s == "--help" || s == "/?"
 }
 
+func validate(ctx context.Context, pkg *pkgconfig.Pkg) {
+	r, err := pkg.CheckContext(ctx)
+	if err != nil {
+		die(err)
+	}
+	for _, p := range r.Problems {
+		fmt.Fprintln(os.Stderr, p)
+	}
+	if !r.Valid() {
+		os.Exit(1)
+	}
+}
+
+// isTerminal tells whether the file is a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
 func main() {
+	timeoutEnv()
+	if isTerminal(os.Stderr) {
+		pkgconfig.DefaultFetcher.Progress = os.Stderr
+	}
 	if len(os.Args) == 1 || (len(os.Args) == 2 && ishelp(os.Args[1])) {
 		fmt.Println(usage)
 	} else {
 		switch os.Args[1] {
 		case "get":
-			if len(os.Args) != 4 {
-				die(usage)
-			}
-			_, err := pkgconfig.LookupGithubProj(os.Args[3], os.Args[2])
-			if err != nil {
-				die(err)
-			}
+			get(os.Args[2:])
+		case "sync":
+			syncLock(os.Args[2:])
+		case "cache":
+			cache(os.Args[2:])
+		case "lint":
+			lint(os.Args[2:])
+		case "fmt":
+			format(os.Args[2:])
+		case "sign":
+			sign(os.Args[2:])
+		case "pack":
+			pack(os.Args[2:])
+		case "serve":
+			serve(os.Args[2:])
+		case "list":
+			list(os.Args[2:])
+		case "remove":
+			remove(os.Args[2:])
 		default:
+			timeoutArgs(os.Args[1:])
+			ctx, cancel := interruptible()
+			defer cancel()
 			pkg := pkgconfig.NewPkgArgs(os.Args[1:])
-			if err := pkg.Resolve(); err == nil {
+			if pkg.Validate {
+				validate(ctx, pkg)
+				return
+			}
+			if err := pkg.ResolveContext(ctx); err == nil {
 				pkg.WriteTo(os.Stdout)
 			} else {
 				die(err)
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"strings"
+
+	"github.com/rjeczalik/pkgconfig"
+)
+
+func pack(args []string) {
+	var (
+		fs      = flag.NewFlagSet("pack", flag.ExitOnError)
+		dir     = fs.String("o", ".", "directory to write the archives to")
+		gopath  = fs.String("gopath", "", "workspace to pack the libraries from; the install root by default")
+		targets = fs.String("target", "", "comma-separated GOOS_GOARCH targets to pack; all the built ones by default")
+	)
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		die(usage)
+	}
+	if *gopath == "" {
+		*gopath = installRoot()
+	}
+	var platforms []string
+	if *targets != "" {
+		platforms = strings.Split(*targets, ",")
+	}
+	for _, lib := range fs.Args() {
+		file := filepath.Join(*dir, lib+".zip")
+		if err := pkgconfig.PackFile(file, *gopath, lib, platforms); err != nil {
+			die(err)
+		}
+	}
+}
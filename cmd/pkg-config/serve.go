@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/rjeczalik/pkgconfig"
+)
+
+func serve(args []string) {
+	var (
+		fs   = flag.NewFlagSet("serve", flag.ExitOnError)
+		addr = fs.String("addr", "localhost:8080", "address to listen on")
+	)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		die(usage)
+	}
+	dir := fs.Arg(0)
+	if fi, err := os.Stat(dir); err != nil {
+		die(err)
+	} else if !fi.IsDir() {
+		die(dir + " is not a directory")
+	}
+	fmt.Fprintf(os.Stderr, "serving %s on %s\n", dir, *addr)
+	die(http.ListenAndServe(*addr, pkgconfig.ProxyHandler(dir)))
+}
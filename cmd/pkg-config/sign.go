@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/rjeczalik/pkgconfig"
+)
+
+func sign(args []string) {
+	var (
+		fs     = flag.NewFlagSet("sign", flag.ExitOnError)
+		key    = fs.String("key", "", "private key file used for signing")
+		genkey = fs.String("genkey", "", "generate a new private key into the file and print the public one")
+	)
+	fs.Parse(args)
+	switch {
+	case *genkey != "":
+		pub, err := pkgconfig.GenerateKey(*genkey)
+		if err != nil {
+			die(err)
+		}
+		fmt.Println(pkgconfig.FormatPublicKey(pub))
+	case *key != "" && fs.NArg() != 0:
+		priv, err := pkgconfig.ReadPrivateKey(*key)
+		if err != nil {
+			die(err)
+		}
+		for _, file := range fs.Args() {
+			if err = pkgconfig.SignFile(file, priv); err != nil {
+				die(err)
+			}
+		}
+	default:
+		die(usage)
+	}
+}
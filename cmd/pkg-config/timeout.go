@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/rjeczalik/pkgconfig"
+)
+
+// timeoutVar is the environment variable, which sets the HTTP timeout when
+// the tool is run by cgo and so cannot be passed the --timeout flag.
+const timeoutVar = "PKG_CONFIG_TIMEOUT"
+
+// timeout is a flag.Value, which limits the time of each HTTP request made
+// by the default fetcher.
+type timeout struct{}
+
+func (timeout) String() string {
+	if c := pkgconfig.DefaultFetcher.Client; c != nil {
+		return c.Timeout.String()
+	}
+	return "0s"
+}
+
+func (timeout) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	if d < 0 {
+		return fmt.Errorf("negative timeout: %s", s)
+	}
+	pkgconfig.DefaultFetcher.Client = &http.Client{Timeout: d}
+	return nil
+}
+
+// timeoutFlag adds the -timeout flag, which limits the time of each HTTP
+// request.
+func timeoutFlag(fs *flag.FlagSet) {
+	fs.Var(timeout{}, "timeout", "time limit for each HTTP request, e.g. 30s; $"+timeoutVar+" or no limit by default")
+}
+
+// timeoutEnv sets the timeout from the environment, if it's set there.
+func timeoutEnv() {
+	if v := os.Getenv(timeoutVar); v != "" {
+		if err := (timeout{}).Set(v); err != nil {
+			die(fmt.Errorf("invalid $%s: %v", timeoutVar, err))
+		}
+	}
+}
+
+// timeoutArgs sets the timeout from a --timeout=DURATION argument, which
+// NewPkgArgs ignores.
+func timeoutArgs(args []string) {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--timeout=") {
+			if err := (timeout{}).Set(strings.TrimPrefix(arg, "--timeout=")); err != nil {
+				die(fmt.Errorf("invalid --timeout: %v", err))
+			}
+		}
+	}
+}
+
+// interruptible gives a context, which is cancelled on interrupt, so the
+// downloads in progress are stopped and their temporary files removed.
+func interruptible() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
@@ -0,0 +1,225 @@
+package pkgconfig
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// DepsExt is the extension of a dependency file, which an archive of LIB
+// may ship next to its .pc file, as lib/$GOOS_$GOARCH/LIB/LIB.deps. The file
+// maps the packages listed in Requires and Requires.private to the projects
+// serving them, one per line:
+//
+//	libbar github.com/USER/BAR
+//
+// Requirements missing from $GOPATH, which are listed in the file, are
+// fetched along with the library.
+const DepsExt = ".deps"
+
+// require is a package required by an installed one, which is served by
+// the given project.
+type require struct {
+	name string
+	proj string
+}
+
+// readDeps parses the dependency file.
+func readDeps(r io.Reader) (map[string]string, error) {
+	var (
+		deps = make(map[string]string)
+		buf  = bufio.NewScanner(r)
+	)
+	for n := 1; buf.Scan(); n++ {
+		v := strings.Fields(buf.Text())
+		if len(v) == 0 || strings.HasPrefix(v[0], "#") {
+			continue
+		}
+		if len(v) != 2 {
+			return nil, fmt.Errorf("line %d: malformed dependency", n)
+		}
+		deps[v[0]] = v[1]
+	}
+	return deps, buf.Err()
+}
+
+// requires gives the requirements of the package, which are listed in its
+// dependency file.
+func requires(pc *PC, deps map[string]string) []require {
+	var reqs []require
+	for _, dep := range append(pc.Requires[:len(pc.Requires):len(pc.Requires)], pc.RequiresPrivate...) {
+		if proj, ok := deps[dep.Name]; ok {
+			reqs = append(reqs, require{name: dep.Name, proj: proj})
+		}
+	}
+	return reqs
+}
+
+// archiveRequires reads the requirements of the package from its .pc and
+// dependency files for the current target within the archive.
+func archiveRequires(file, pkg string) ([]require, error) {
+	var (
+		dir  = "lib/" + runtime.GOOS + "_" + runtime.GOARCH + "/" + pkg + "/"
+		pc   *PC
+		deps map[string]string
+	)
+	err := walkArchive(file, func(e *entry) (err error) {
+		if e.r == nil {
+			return nil
+		}
+		switch e.name {
+		case dir + pkg + ".pc":
+			var f *PCFile
+			if f, err = ParsePCFile(e.r); err == nil {
+				pc, err = f.PC(map[string]string{"GOOS": runtime.GOOS, "GOARCH": runtime.GOARCH, "GOPATH": ""})
+			}
+		case dir + pkg + DepsExt:
+			deps, err = readDeps(e.r)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", e.name, err)
+		}
+		return nil
+	})
+	if err != nil || pc == nil || deps == nil {
+		return nil, err
+	}
+	return requires(pc, deps), nil
+}
+
+// installedRequires reads the requirements of the installed package.
+func installedRequires(pc *PC, pkg string) ([]require, error) {
+	name, _ := splittag(pkg)
+	f, err := os.Open(filepath.Join(filepath.Dir(pc.File), name+DepsExt))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	deps, err := readDeps(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", f.Name(), err)
+	}
+	return requires(pc, deps), nil
+}
+
+// pcRoot gives the workspace the .pc file of the package was found in.
+func pcRoot(pc *PC, pkg string) string {
+	// The file is ROOT/lib/TARGET/LIB/LIB.pc.
+	root := filepath.Dir(filepath.Dir(filepath.Dir(filepath.Dir(pc.File))))
+	if name, _ := splittag(pkg); name != pkg {
+		// The TagRoot is ROOT/pkg/pkg-config/LIB@TAG.
+		root = filepath.Dir(filepath.Dir(filepath.Dir(root)))
+	}
+	return root
+}
+
+// Step is a single package of an installation plan.
+type Step struct {
+	LockEntry
+	// RequiredBy is the package which requires this one. It's empty for
+	// the requested package.
+	RequiredBy string
+	// Installed is true if the package was already installed, thus it was not
+	// fetched. The URL and Sum of such a package are taken from its install
+	// manifest and are empty if it has none.
+	Installed bool
+	// Root is the workspace the package is installed into.
+	Root string
+}
+
+func (s Step) String() string {
+	var via string
+	if s.RequiredBy != "" {
+		via = " (required by " + s.RequiredBy + ")"
+	}
+	if s.Installed {
+		return s.Lib + " " + s.Proj + " installed" + via
+	}
+	return s.Lib + " " + s.Proj + " " + s.URL + via
+}
+
+// GetAll installs the package with Get and then fetches recursively all its
+// requirements, which are missing from $GOPATH, from the projects their
+// dependency files point to. Each package is fetched at most once, so cyclic
+// requirements are fine. The returned steps describe what was installed,
+// in order. If dry is true, archives are downloaded and verified, but nothing
+// is installed; the steps give the plan.
+func (f *Fetcher) GetAll(pkg, proj string, dry bool) ([]Step, error) {
+	return f.GetAllContext(context.Background(), pkg, proj, dry)
+}
+
+// GetAllContext is GetAll, which stops as soon as the context is done.
+func (f *Fetcher) GetAllContext(ctx context.Context, pkg, proj string, dry bool) ([]Step, error) {
+	return f.getAll(ctx, pkg, proj, dry, false)
+}
+
+// getAll is GetAll, which if reuse is true does not fetch the requested
+// package if it's already installed.
+func (f *Fetcher) getAll(ctx context.Context, pkg, proj string, dry, reuse bool) ([]Step, error) {
+	path, err := f.InstallRoot()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		steps []Step
+		queue = []Step{{LockEntry: LockEntry{Lib: pkg, Proj: proj}}}
+		seen  = map[string]struct{}{pkg: {}}
+	)
+	for len(queue) != 0 {
+		if err := ctx.Err(); err != nil {
+			return steps, err
+		}
+		s := queue[0]
+		queue = queue[1:]
+		reqs, err := f.step(ctx, path, &s, dry, reuse || s.RequiredBy != "")
+		if err != nil {
+			if s.RequiredBy != "" {
+				err = fmt.Errorf("%s required by %s: %v", s.Lib, s.RequiredBy, err)
+			}
+			return steps, err
+		}
+		steps = append(steps, s)
+		for _, r := range reqs {
+			if _, ok := seen[r.name]; ok {
+				continue
+			}
+			seen[r.name] = struct{}{}
+			queue = append(queue, Step{LockEntry: LockEntry{Lib: r.name, Proj: r.proj}, RequiredBy: s.Lib})
+		}
+	}
+	return steps, nil
+}
+
+func (f *Fetcher) step(ctx context.Context, path string, s *Step, dry, reuse bool) ([]require, error) {
+	unlock, err := lockLib(path, s.Lib)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	s.Root = path
+	if reuse {
+		if pc, err := f.lookupGopath(path, s.Lib); err == nil {
+			s.Installed, s.Root = true, pcRoot(pc, s.Lib)
+			// The origin of the package is known only from its install
+			// manifest; one installed by other means has none.
+			if m, err := ReadManifest(s.Root, s.Lib); err == nil {
+				s.LockEntry = m.LockEntry
+			}
+			return installedRequires(pc, s.Lib)
+		}
+	}
+	e, reqs, err := f.get(ctx, path, s.Lib, s.Proj, dry)
+	if err != nil {
+		return nil, err
+	}
+	s.LockEntry = *e
+	return reqs, nil
+}
@@ -0,0 +1,128 @@
+package pkgconfig
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func libzip(t *testing.T, name, requires, deps string) []byte {
+	dir := "lib/" + target + "/" + name + "/"
+	files := map[string]string{
+		"include/" + name + "/" + name + ".h": "",
+		dir + name + ".pc": "\nName: " + name + "\nVersion: 1.0\nRequires: " + requires +
+			"\nLibs: -L${GOPATH}/lib/${GOOS}_${GOARCH}/" + name + " -l" + name + "\n",
+	}
+	if deps != "" {
+		files[dir+name+DepsExt] = deps
+	}
+	return newzip(t, files)
+}
+
+func TestFetcherGetAll(t *testing.T) {
+	dir, restore := tempgopath(t)
+	defer restore()
+	archives := map[string][]byte{
+		"/github.com/user/foo/releases/download/pkg-config/libfoo.zip": libzip(t, "libfoo",
+			"libbar >= 1.0, zlib", "# libbar is a separate project\nlibbar github.com/user/bar\n"),
+		"/github.com/user/bar/releases/download/pkg-config/libbar.zip": libzip(t, "libbar",
+			"libfoo libbaz", "libfoo github.com/user/foo\nlibbaz github.com/user/baz\n"),
+		"/github.com/user/baz/releases/download/pkg-config/libbaz.zip": libzip(t, "libbaz", "", ""),
+	}
+	var reqs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := archives[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		reqs = append(reqs, r.URL.Path)
+		w.Write(p)
+	}))
+	defer srv.Close()
+	f := &Fetcher{Client: srv.Client(), BaseURL: srv.URL + "/"}
+	names := func(steps []Step) string {
+		var s []string
+		for _, step := range steps {
+			v := step.Lib + "<" + step.RequiredBy
+			if step.Installed {
+				v += "!"
+			}
+			s = append(s, v)
+		}
+		return strings.Join(s, " ")
+	}
+	steps, err := f.GetAll("libfoo", "github.com/user/foo", true)
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if s, exp := names(steps), "libfoo< libbar<libfoo libbaz<libbar"; s != exp {
+		t.Errorf("expected steps=%q; was %q", exp, s)
+	}
+	if err = existDir(filepath.Join(dir, "include")); err == nil {
+		t.Error("expected nothing to be installed with dry run")
+	}
+	if steps, err = f.GetAll("libfoo", "github.com/user/foo", false); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if s, exp := names(steps), "libfoo< libbar<libfoo libbaz<libbar"; s != exp {
+		t.Errorf("expected steps=%q; was %q", exp, s)
+	}
+	for i, step := range steps {
+		if step.URL == "" || step.Sum == "" {
+			t.Errorf("expected url and sum to be set; was %+v (i=%d)", step, i)
+		}
+		if _, err := LookupGopath(step.Lib); err != nil {
+			t.Errorf("expected err=nil; was %q (i=%d)", err, i)
+		}
+	}
+	n := len(reqs)
+	// Installed requirements are not fetched again.
+	if steps, err = f.GetAll("libbar", "github.com/user/bar", false); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if s, exp := names(steps), "libbar< libfoo<libbar! libbaz<libbar!"; s != exp {
+		t.Errorf("expected steps=%q; was %q", exp, s)
+	}
+	if len(reqs) != n+1 {
+		t.Errorf("expected one download; was %v", reqs[n:])
+	}
+	// The origin of the installed requirements is known from their manifests.
+	for i, step := range steps {
+		if step.URL == "" || step.Sum == "" {
+			t.Errorf("expected url and sum to be set; was %+v (i=%d)", step, i)
+		}
+	}
+}
+
+func TestFetcherGetAllErr(t *testing.T) {
+	_, restore := tempgopath(t)
+	defer restore()
+	p := libzip(t, "libfoo", "libbar", "libbar github.com/user/bar\n")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/github.com/user/foo/releases/download/pkg-config/libfoo.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(p)
+	}))
+	defer srv.Close()
+	f := &Fetcher{Client: srv.Client(), BaseURL: srv.URL + "/"}
+	steps, err := f.GetAll("libfoo", "github.com/user/foo", false)
+	if err == nil || !strings.Contains(err.Error(), "libbar required by libfoo") {
+		t.Errorf("expected missing libbar error; was %v", err)
+	}
+	if len(steps) != 1 || steps[0].Lib != "libfoo" {
+		t.Errorf("expected libfoo to be installed; was %v", steps)
+	}
+}
+
+func TestReadDepsErr(t *testing.T) {
+	for i, s := range []string{"libbar\n", "libbar github.com/user/bar extra\n"} {
+		if _, err := readDeps(strings.NewReader(s)); err == nil {
+			t.Errorf("expected err!=nil (i=%d)", i)
+		}
+	}
+}
@@ -0,0 +1,415 @@
+package pkgconfig
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRetries is the number of times DefaultFetcher retries a request,
+// which failed with a transient error.
+const DefaultRetries = 3
+
+// DefaultBackoff is the delay before the first retry of a Fetcher with
+// no Backoff set.
+const DefaultBackoff = time.Second
+
+// maxBackoff limits the delay between two retries.
+const maxBackoff = 30 * time.Second
+
+// errResume means the partially downloaded file was discarded, as it did not
+// match the content, and the download must be started over.
+var errResume = errors.New("unable to resume the download, starting over")
+
+// statusError is an unexpected response to a request.
+type statusError struct {
+	url        string
+	status     string
+	code       int
+	retryAfter time.Duration
+}
+
+func newStatusError(url string, res *http.Response) *statusError {
+	e := &statusError{url: url, status: res.Status, code: res.StatusCode}
+	if n, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && n > 0 {
+		e.retryAfter = time.Duration(n) * time.Second
+	}
+	return e
+}
+
+func (e *statusError) Error() string {
+	return "unexpected response for " + e.url + ": " + e.status
+}
+
+// transient tells whether the request, which failed with the given error,
+// may succeed if retried.
+func transient(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		switch se.code {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+			http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	if errors.Is(err, errResume) {
+		return true
+	}
+	// A *url.Error is a net.Error itself, whatever it wraps, like a refused
+	// redirect or an untrusted certificate.
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		err = ue.Err
+	}
+	var ne net.Error
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &ne)
+}
+
+// retry calls fn until it succeeds, it fails with an error which is not
+// transient or it's retried f.Retries times. The delay between the calls
+// starts with f.Backoff and is doubled each time, unless the server asks
+// for a longer one.
+func (f *Fetcher) retry(ctx context.Context, fn func() error) error {
+	delay := f.Backoff
+	if delay <= 0 {
+		delay = DefaultBackoff
+	}
+	for i := 0; ; i++ {
+		err := fn()
+		if err == nil || i >= f.Retries || ctx.Err() != nil || !transient(err) {
+			return err
+		}
+		d := delay
+		var se *statusError
+		if errors.As(err, &se) && se.retryAfter > d {
+			d = se.retryAfter
+		}
+		if d > maxBackoff {
+			d = maxBackoff
+		}
+		t := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		if delay < maxBackoff {
+			delay *= 2
+		}
+	}
+}
+
+// partial is a file a download is written to, which is resumed from where
+// it stopped if it's retried.
+type partial struct {
+	file *os.File
+	// validator is the strong ETag or the Last-Modified date of the content
+	// written to the file, which is sent in If-Range when resuming.
+	validator string
+	// info is the file the validator is kept in for the downloads cached
+	// across fetches; it's empty for the temporary ones.
+	info   string
+	unlock func()
+}
+
+// openPartial opens the file the download of the url is resumed from by any
+// following fetch, waiting for a concurrent download of the same url
+// to finish first.
+func (c *Cache) openPartial(url string) (*partial, error) {
+	h := sha256.Sum256([]byte(url))
+	name := filepath.Join(c.Dir, "partial", hex.EncodeToString(h[:]))
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return nil, err
+	}
+	unlock, err := openLock(name + ".lock")
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	p := &partial{file: f, info: name + ".info", unlock: unlock}
+	// A partial file of an unknown content cannot be resumed.
+	if v, err := ioutil.ReadFile(p.info); err == nil {
+		p.validator = strings.TrimSpace(string(v))
+	}
+	return p, nil
+}
+
+// tempPartial creates a temporary file for a download, which is not resumed
+// across fetches.
+func tempPartial(pkg string) (*partial, error) {
+	f, err := ioutil.TempFile("", pkg)
+	if err != nil {
+		return nil, err
+	}
+	return &partial{file: f, unlock: func() {}}, nil
+}
+
+// reset discards the content of the file.
+func (p *partial) reset() error {
+	p.validator = ""
+	if p.info != "" {
+		os.Remove(p.info)
+	}
+	if err := p.file.Truncate(0); err != nil {
+		return err
+	}
+	_, err := p.file.Seek(0, io.SeekStart)
+	return err
+}
+
+// setValidator sets the validator of the content being downloaded.
+func (p *partial) setValidator(h http.Header) error {
+	p.validator = ""
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		p.validator = etag
+	} else if h.Get("Last-Modified") != "" {
+		p.validator = h.Get("Last-Modified")
+	}
+	if p.info == "" {
+		return nil
+	}
+	if p.validator == "" {
+		os.Remove(p.info)
+		return nil
+	}
+	return writeFile(p.info, strings.NewReader(p.validator+"\n"))
+}
+
+// close closes the file, keeping it for resuming if keep is true; otherwise
+// the file is removed.
+func (p *partial) close(keep bool) {
+	p.file.Close()
+	if !keep || p.info == "" || p.validator == "" {
+		os.Remove(p.file.Name())
+		if p.info != "" {
+			os.Remove(p.info)
+		}
+	}
+	p.unlock()
+}
+
+// done closes the completely downloaded file and gives a name of it, which
+// no following download is written to.
+func (p *partial) done() (string, error) {
+	defer p.unlock()
+	name := p.file.Name()
+	if err := p.file.Close(); err != nil {
+		os.Remove(name)
+		return "", err
+	}
+	if p.info == "" {
+		return name, nil
+	}
+	os.Remove(p.info)
+	tmp, err := ioutil.TempFile(filepath.Dir(name), ".tmp-")
+	if err == nil {
+		tmp.Close()
+		err = os.Rename(name, tmp.Name())
+	}
+	if err != nil {
+		os.Remove(name)
+		if tmp != nil {
+			os.Remove(tmp.Name())
+		}
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// download fetches the url into a temporary file, which is the caller's
+// responsibility to remove. A download, which fails with a transient error,
+// is retried and resumed from where it stopped, if the server supports range
+// requests. With the cache set, the partially downloaded file is kept there
+// once the retries are exhausted, so the next fetch resumes it as well.
+func (f *Fetcher) download(ctx context.Context, url, pkg string) (string, error) {
+	var (
+		p   *partial
+		err error
+	)
+	if f.Cache != nil {
+		p, err = f.Cache.openPartial(url)
+	} else {
+		p, err = tempPartial(pkg)
+	}
+	if err != nil {
+		return "", err
+	}
+	pr := newProgress(f.Progress, path.Base(url))
+	err = f.retry(ctx, func() error { return f.resume(ctx, p, url, pr) })
+	pr.done(err)
+	if err != nil {
+		p.close(transient(err) || ctx.Err() != nil)
+		return "", err
+	}
+	return p.done()
+}
+
+// resume downloads the url into the partial file, requesting the missing
+// part of the content only, if the file has any.
+func (f *Fetcher) resume(ctx context.Context, p *partial, url string, pr *progress) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	off, err := p.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if off != 0 && p.validator != "" {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(off, 10)+"-")
+		req.Header.Set("If-Range", p.validator)
+	}
+	res, err := f.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+		// The content has changed or the range was not honored.
+		if off != 0 {
+			if err = p.reset(); err != nil {
+				return err
+			}
+			off = 0
+		}
+	case http.StatusPartialContent:
+		if req.Header.Get("Range") == "" || contentRangeStart(res.Header.Get("Content-Range")) != off {
+			if err = p.reset(); err != nil {
+				return err
+			}
+			return fmt.Errorf("%s: unexpected Content-Range %q: %w", url, res.Header.Get("Content-Range"), errResume)
+		}
+	case http.StatusNotFound:
+		return notFoundError(url)
+	case http.StatusRequestedRangeNotSatisfiable:
+		if err = p.reset(); err != nil {
+			return err
+		}
+		return fmt.Errorf("%s: %s: %w", url, res.Status, errResume)
+	default:
+		return newStatusError(url, res)
+	}
+	if res.StatusCode == http.StatusOK {
+		if err = p.setValidator(res.Header); err != nil {
+			return err
+		}
+	}
+	total := int64(-1)
+	if res.ContentLength >= 0 {
+		total = off + res.ContentLength
+	}
+	pr.start(off, total)
+	_, err = io.Copy(p.file, io.TeeReader(res.Body, pr))
+	return err
+}
+
+// contentRangeStart gives the first byte position of the "bytes FIRST-LAST/SIZE"
+// Content-Range header or -1, if the header is malformed.
+func contentRangeStart(s string) int64 {
+	if !strings.HasPrefix(s, "bytes ") {
+		return -1
+	}
+	s = strings.TrimPrefix(s, "bytes ")
+	i := strings.IndexByte(s, '-')
+	if i == -1 {
+		return -1
+	}
+	n, err := strconv.ParseInt(s[:i], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// progress reports the progress of a download, at most a few times
+// a second. A nil *progress reports nothing.
+type progress struct {
+	w     io.Writer
+	name  string
+	n     int64
+	total int64
+	last  time.Time
+	shown bool
+}
+
+func newProgress(w io.Writer, name string) *progress {
+	if w == nil {
+		return nil
+	}
+	return &progress{w: w, name: name, total: -1}
+}
+
+func (pr *progress) start(n, total int64) {
+	if pr != nil {
+		pr.n, pr.total = n, total
+	}
+}
+
+func (pr *progress) Write(p []byte) (int, error) {
+	if pr == nil {
+		return len(p), nil
+	}
+	pr.n += int64(len(p))
+	if now := time.Now(); now.Sub(pr.last) >= 200*time.Millisecond {
+		pr.last = now
+		pr.print()
+	}
+	return len(p), nil
+}
+
+func (pr *progress) print() {
+	pr.shown = true
+	if pr.total > 0 {
+		fmt.Fprintf(pr.w, "\rdownloading %s: %s / %s (%d%%)", pr.name, size(pr.n), size(pr.total),
+			pr.n*100/pr.total)
+		return
+	}
+	fmt.Fprintf(pr.w, "\rdownloading %s: %s", pr.name, size(pr.n))
+}
+
+// done ends the progress line, if any was printed.
+func (pr *progress) done(err error) {
+	if pr == nil || !pr.shown {
+		return
+	}
+	pr.print()
+	if err != nil {
+		fmt.Fprintln(pr.w, " failed")
+		return
+	}
+	fmt.Fprintln(pr.w)
+}
+
+// size formats the number of bytes in binary units.
+func size(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
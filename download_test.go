@@ -0,0 +1,285 @@
+package pkgconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// dropWriter writes at most n bytes of the response and then drops
+// the connection.
+type dropWriter struct {
+	http.ResponseWriter
+	n int
+}
+
+func (w *dropWriter) Write(p []byte) (int, error) {
+	if len(p) <= w.n {
+		w.n -= len(p)
+		return w.ResponseWriter.Write(p)
+	}
+	w.ResponseWriter.Write(p[:w.n])
+	w.ResponseWriter.(http.Flusher).Flush()
+	panic(http.ErrAbortHandler)
+}
+
+// flakyServer serves the libfoo archive with range requests supported,
+// dropping the connection after a quarter of the content was sent for
+// the first drops requests. It records the Range header of each request.
+type flakyServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	ranges []string
+}
+
+func newFlakyServer(p []byte, drops int) *flakyServer {
+	srv := &flakyServer{}
+	h := sha256.Sum256(p)
+	mux := http.NewServeMux()
+	mux.HandleFunc(libfooPath+".sha256", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s  libfoo.zip\n", hex.EncodeToString(h[:]))
+	})
+	mux.HandleFunc(libfooPath, func(w http.ResponseWriter, r *http.Request) {
+		srv.mu.Lock()
+		srv.ranges = append(srv.ranges, r.Header.Get("Range"))
+		n := len(srv.ranges)
+		srv.mu.Unlock()
+		w.Header().Set("ETag", `"libfoo-1"`)
+		if n <= drops {
+			w = &dropWriter{ResponseWriter: w, n: len(p) / 4}
+		}
+		http.ServeContent(w, r, "libfoo.zip", time.Time{}, bytes.NewReader(p))
+	})
+	srv.Server = httptest.NewServer(mux)
+	return srv
+}
+
+func (srv *flakyServer) requests() []string {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return append([]string(nil), srv.ranges...)
+}
+
+func TestDownloadResume(t *testing.T) {
+	dir, restore := tempgopath(t)
+	defer restore()
+	p := libfoozip(t)
+	srv := newFlakyServer(p, 2)
+	defer srv.Close()
+	var buf bytes.Buffer
+	f := &Fetcher{Client: srv.Client(), BaseURL: srv.URL + "/", Retries: 3, Backoff: time.Millisecond,
+		Progress: &buf}
+	if _, err := f.Get("libfoo", "github.com/user/proj"); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if err := existFile(filepath.Join(dir, "include", "libfoo", "foo.h")); err != nil {
+		t.Errorf("expected err=nil; was %q", err)
+	}
+	ranges := srv.requests()
+	if len(ranges) != 3 {
+		t.Fatalf("expected 3 requests; was %d", len(ranges))
+	}
+	for i, exp := range []string{"", fmt.Sprintf("bytes=%d-", len(p)/4), fmt.Sprintf("bytes=%d-", 2*(len(p)/4))} {
+		if ranges[i] != exp {
+			t.Errorf("expected Range=%q; was %q (i=%d)", exp, ranges[i], i)
+		}
+	}
+	if s := buf.String(); !strings.Contains(s, "downloading libfoo.zip") || !strings.HasSuffix(s, "(100%)\n") {
+		t.Errorf("expected progress of libfoo.zip; was %q", s)
+	}
+}
+
+func TestDownloadResumeCache(t *testing.T) {
+	_, restore := tempgopath(t)
+	defer restore()
+	cachedir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	defer os.RemoveAll(cachedir)
+	p := libfoozip(t)
+	srv := newFlakyServer(p, 2)
+	defer srv.Close()
+	f := &Fetcher{Client: srv.Client(), BaseURL: srv.URL + "/", Cache: &Cache{Dir: cachedir},
+		Retries: 1, Backoff: time.Millisecond}
+	if _, err = f.Get("libfoo", "github.com/user/proj"); err == nil {
+		t.Fatal("expected err!=nil")
+	}
+	// The next fetch resumes the download the failed one has stopped.
+	if _, err = f.Get("libfoo", "github.com/user/proj"); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	ranges := srv.requests()
+	if exp := fmt.Sprintf("bytes=%d-", 2*(len(p)/4)); len(ranges) != 3 || ranges[2] != exp {
+		t.Errorf("expected third request with Range=%q; was %q", exp, ranges)
+	}
+	fis, err := ioutil.ReadDir(filepath.Join(cachedir, "partial"))
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	for _, fi := range fis {
+		if !strings.HasSuffix(fi.Name(), ".lock") {
+			t.Errorf("expected completed download to be removed; was %s", fi.Name())
+		}
+	}
+}
+
+func TestDownloadResumeChanged(t *testing.T) {
+	dir, restore := tempgopath(t)
+	defer restore()
+	cachedir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	defer os.RemoveAll(cachedir)
+	c := &Cache{Dir: cachedir}
+	old := newFlakyServer(newzip(t, map[string]string{"include/libfoo/old.h": strings.Repeat("old", 100)}), 1)
+	defer old.Close()
+	f := &Fetcher{Client: old.Client(), BaseURL: old.URL + "/", Cache: c}
+	if _, err = f.Get("libfoo", "github.com/user/proj"); err == nil {
+		t.Fatal("expected err!=nil")
+	}
+	// The archive changed in the meantime, so its ETag does not match
+	// the partially downloaded one.
+	p := libfoozip(t)
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != libfooPath {
+			http.NotFound(w, r)
+			return
+		}
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("ETag", `"libfoo-2"`)
+		http.ServeContent(w, r, "libfoo.zip", time.Time{}, bytes.NewReader(p))
+	}))
+	defer srv.Close()
+	// The url must be the same as the one of the partial download.
+	client := &http.Client{Transport: redirectTransport{srv.Listener.Addr().String()}}
+	f = &Fetcher{Client: client, BaseURL: old.URL + "/", Cache: c}
+	if _, err = f.Get("libfoo", "github.com/user/proj"); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if len(ranges) != 1 || ranges[0] == "" {
+		t.Errorf("expected a single range request; was %q", ranges)
+	}
+	if err = existFile(filepath.Join(dir, "include", "libfoo", "foo.h")); err != nil {
+		t.Errorf("expected err=nil; was %q", err)
+	}
+}
+
+// redirectTransport sends all the requests to the given address.
+type redirectTransport struct {
+	addr string
+}
+
+func (t redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Host = t.addr
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestDownloadRetries(t *testing.T) {
+	_, restore := tempgopath(t)
+	defer restore()
+	cases := [...]struct {
+		code int
+		n    int
+	}{
+		{http.StatusForbidden, 1},
+		{http.StatusUnauthorized, 1},
+		{http.StatusServiceUnavailable, 3},
+		{http.StatusTooManyRequests, 3},
+		{http.StatusBadGateway, 3},
+	}
+	for i, cas := range cases {
+		var n int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != libfooPath {
+				http.NotFound(w, r)
+				return
+			}
+			n++
+			http.Error(w, http.StatusText(cas.code), cas.code)
+		}))
+		f := &Fetcher{Client: srv.Client(), BaseURL: srv.URL + "/", Retries: 2, Backoff: time.Millisecond}
+		if _, err := f.Get("libfoo", "github.com/user/proj"); err == nil {
+			t.Errorf("expected err!=nil (i=%d)", i)
+		}
+		srv.Close()
+		if n != cas.n {
+			t.Errorf("expected n=%d; was %d (i=%d)", cas.n, n, i)
+		}
+	}
+}
+
+func TestDownloadRetryCancel(t *testing.T) {
+	_, restore := tempgopath(t)
+	defer restore()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	f := &Fetcher{Client: srv.Client(), BaseURL: srv.URL + "/", Retries: 10, Backoff: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := f.GetContext(ctx, "libfoo", "github.com/user/proj"); err != context.DeadlineExceeded {
+		t.Errorf("expected err=%q; was %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestTransient(t *testing.T) {
+	cases := [...]struct {
+		err error
+		ok  bool
+	}{
+		{io.ErrUnexpectedEOF, true},
+		{&url.Error{Op: "Get", URL: "x", Err: io.EOF}, true},
+		{&url.Error{Op: "Get", URL: "x", Err: &net.OpError{Op: "read", Err: errors.New("connection reset")}}, true},
+		{fmt.Errorf("x: %w", errResume), true},
+		{&statusError{code: http.StatusServiceUnavailable}, true},
+		{&statusError{code: http.StatusNotFound}, false},
+		{&url.Error{Op: "Get", URL: "x", Err: errInsecureRedirect}, false},
+		{&url.Error{Op: "Get", URL: "x", Err: context.Canceled}, false},
+		{notFoundError("x"), false},
+		{&ChecksumError{}, false},
+	}
+	for i, cas := range cases {
+		if ok := transient(cas.err); ok != cas.ok {
+			t.Errorf("expected ok=%t; was %t (i=%d)", cas.ok, ok, i)
+		}
+	}
+}
+
+func TestSize(t *testing.T) {
+	cases := [...]struct {
+		n   int64
+		exp string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{5 << 20, "5.0 MiB"},
+		{3 << 30, "3.0 GiB"},
+	}
+	for i, cas := range cases {
+		if s := size(cas.n); s != cas.exp {
+			t.Errorf("expected s=%q; was %q (i=%d)", cas.exp, s, i)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package pkgconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// lockLib acquires an exclusive, cross-process lock for installing the given
+// package into the $GOPATH workspace, waiting until the lock is released by
+// any other holder. The returned function releases the lock.
+//
+// The lock is a LIB.lock file within the $GOPATH/pkg/pkg-config directory,
+// which is never removed, as removing it would race with the waiting processes.
+func lockLib(path, pkg string) (func(), error) {
+	if !validPkg(pkg) {
+		return nil, fmt.Errorf("invalid package name %q", pkg)
+	}
+	dir := filepath.Join(path, "pkg", "pkg-config")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return openLock(filepath.Join(dir, pkg+".lock"))
+}
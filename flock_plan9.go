@@ -0,0 +1,22 @@
+package pkgconfig
+
+import (
+	"os"
+	"time"
+)
+
+// openLock waits until it's able to open the lock file. Plan 9 has no
+// advisory locks; instead the file has the exclusive-use mode, which allows
+// only one client to have it opened at a time.
+func openLock(name string) (func(), error) {
+	for {
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, os.ModeExclusive|0644)
+		if err == nil {
+			return func() { f.Close() }, nil
+		}
+		if os.IsNotExist(err) || os.IsPermission(err) {
+			return nil, err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,48 @@
+package pkgconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLockLib(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	defer os.RemoveAll(dir)
+	unlock, err := lockLib(dir, "libfoo")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	// A lock of another package must not wait.
+	other, err := lockLib(dir, "libbar@v1.0")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	other()
+	locked := make(chan func())
+	go func() {
+		unlock, err := lockLib(dir, "libfoo")
+		if err != nil {
+			t.Errorf("expected err=nil; was %q", err)
+		}
+		locked <- unlock
+	}()
+	select {
+	case <-locked:
+		t.Fatal("expected the second lock to wait")
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case unlock := <-locked:
+		if unlock != nil {
+			unlock()
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the second lock to be acquired")
+	}
+}
@@ -1,16 +1,17 @@
 package pkgconfig
 
 import (
-	"archive/zip"
+	"context"
+	"crypto/ed25519"
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 var githubProj, wd string
@@ -18,8 +19,24 @@ var githubProj, wd string
 func init() {
 	var err error
 	if wd, err = os.Getwd(); err == nil {
+		// Hosts must be known before guessing the project.
+		if hosts, err := ReadHostsFile(findFile(wd, wd, HostsFileName)); err == nil {
+			DefaultHosts = append(hosts, DefaultHosts...)
+		}
 		githubProj = extractproj(wd, os.PathSeparator)
+		// The workspace of the current directory goes first.
+		defaultGopath = gopath(os.PathListSeparator)
+		root := projroot(wd, githubProj)
+		DefaultFetcher.SumFile = findFile(wd, root, SumFileName)
+		DefaultFetcher.KeysFile = findFile(wd, root, KeysFileName)
+	}
+	if dir := DefaultCacheDir(); dir != "" {
+		DefaultFetcher.Cache = &Cache{Dir: dir}
 	}
+	DefaultFetcher.Offline = os.Getenv("PKG_CONFIG_OFFLINE") == "1"
+	DefaultFetcher.Token = EnvToken
+	DefaultFetcher.Netrc = DefaultNetrc()
+	DefaultFetcher.Proxy = ParseProxy(os.Getenv(ProxyEnv))
 }
 
 var src = map[rune]string{'/': "/src/", '\\': `\src\`}
@@ -45,7 +62,7 @@ func extractproj(path string, sep rune) (proj string) {
 		m += l + 1
 	}
 	proj = projpath[sep](string(path[n : m-1]))
-	if !strings.HasPrefix(proj, "github.com") {
+	if _, ok := DefaultHosts.Match(proj); !ok {
 		proj = ""
 	}
 	return
@@ -53,10 +70,16 @@ func extractproj(path string, sep rune) (proj string) {
 
 // LookupGithub TODO(rjeczalik): document
 func LookupGithub(pkg string) (*PC, error) {
+	return LookupGithubContext(context.Background(), pkg)
+}
+
+// LookupGithubContext is LookupGithub, which cancels the downloads when
+// the context is done.
+func LookupGithubContext(ctx context.Context, pkg string) (*PC, error) {
 	if githubProj == "" {
 		return nil, errors.New(`unable to guess project's URL from $CWD`)
 	}
-	return LookupGithubProj(pkg, githubProj)
+	return LookupGithubProjContext(ctx, pkg, githubProj)
 }
 
 var targets = map[string]struct{}{
@@ -82,6 +105,9 @@ var targets = map[string]struct{}{
 const targetsMinKeyLen = 9
 
 func validFile(path, pkg string) bool {
+	if !safeName(path) {
+		return false
+	}
 	switch {
 	case strings.HasPrefix(path, "include/"):
 		i := len("include/")
@@ -119,71 +145,446 @@ var replacefile = map[rune]func(string) string{
 	'\\': func(s string) string { return strings.Replace(s, "/", "\\", -1) },
 }
 
-func copyFile(gopath string, f *zip.File) (err error) {
-	rc, err := f.Open()
-	if err != nil {
-		return
+// validLink reports whether the symlink target, relative to the name
+// of the symlink, stays within the install root.
+func validLink(name, link string) bool {
+	if link == "" || path.IsAbs(link) || strings.Contains(link, `\`) || filepath.VolumeName(link) != "" {
+		return false
 	}
-	defer rc.Close()
-	dir := filepath.Join(gopath, replacefile[os.PathSeparator](path.Dir(f.Name)))
+	target := path.Join(path.Dir(name), link)
+	return target != ".." && !strings.HasPrefix(target, "../")
+}
+
+func copyFile(gopath string, e *entry) (err error) {
+	dir := filepath.Join(gopath, replacefile[os.PathSeparator](path.Dir(e.name)))
 	if err = os.MkdirAll(dir, 0755); err != nil {
 		return
 	}
-	file, err := os.OpenFile(filepath.Join(dir, path.Base(f.Name)), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	name := filepath.Join(dir, path.Base(e.name))
+	if e.mode&os.ModeSymlink != 0 {
+		if !validLink(e.name, e.link) {
+			return fmt.Errorf("symlink %q points outside of the install root: %q", e.name, e.link)
+		}
+		if err = os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return
+		}
+		return os.Symlink(replacefile[os.PathSeparator](e.link), name)
+	}
+	// Only the execute bits are taken from the archive, the rest is left
+	// to the umask.
+	perm := 0644 | e.mode.Perm()&0111
+	// A file could be a symlink left from the previous installation.
+	if fi, err := os.Lstat(name); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		os.Remove(name)
+	}
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
 	if err != nil {
 		return
 	}
-	_, err = io.Copy(file, rc)
-	file.Close()
+	_, err = io.Copy(file, e.r)
+	if e := file.Close(); err == nil {
+		err = e
+	}
 	return
 }
 
-// LookupGithunProj TODO(rjeczalik): document
-func LookupGithubProj(pkg, proj string) (*PC, error) {
-	path := os.Getenv("GOPATH")
-	if p := strings.Split(path, string(os.PathListSeparator)); len(path) != 0 {
-		path = p[0]
+// Fetcher downloads library archives from project releases and unpacks them
+// into $GOPATH. Projects are served by the hosts the Hosts registry maps them
+// to, like github.com, gitlab.com or a private Gitea instance.
+type Fetcher struct {
+	// Client is used for downloading archives. If nil, http.DefaultClient
+	// is used.
+	Client *http.Client
+	// BaseURL is prepended to the project path in order to build the archive
+	// URL. If empty, "https://" is used.
+	BaseURL string
+	// SumFile is a path of the cdeps.sum file, which downloaded archives are
+	// verified against and their checksums are recorded in. If empty, only
+	// the LIBRARY.zip.sha256 assets published next to the archives are used
+	// for verification.
+	SumFile string
+	// Keys maps project paths to public keys trusted to sign their archives.
+	// If any keys are trusted for a project, the LIBRARY.zip.sig asset must
+	// hold a valid signature made by one of them.
+	Keys map[string][]ed25519.PublicKey
+	// KeysFile is a path of the cdeps.keys file, which holds additional
+	// trusted keys. It's not an error if the file does not exist.
+	KeysFile string
+	// Cache stores the downloaded archives. If nil, archives are not cached.
+	Cache *Cache
+	// Offline disables downloading; only the cached archives are installed.
+	Offline bool
+	// Hosts maps projects to URLs of their archives. If nil, DefaultHosts
+	// is used.
+	Hosts Hosts
+	// Token gives the bearer token for the given host. If nil or if it returns
+	// empty string, no token is sent.
+	Token func(host string) string
+	// Netrc is a path of the netrc file, which login and password are sent
+	// to the hosts with no token. Credentials are sent over https only.
+	Netrc string
+	// Root is the workspace libraries are installed into. If empty, it's
+	// the first workspace libraries are looked up in, see InstallRoot.
+	Root string
+	// Retries is the number of times a request, which failed with a transient
+	// error like a dropped connection or a 503 response, is retried. Archive
+	// downloads are resumed, if the server supports range requests.
+	Retries int
+	// Backoff is the delay before the first retry, which is doubled for each
+	// following one. If zero, DefaultBackoff is used.
+	Backoff time.Duration
+	// Progress, if non-nil, is where the progress of archive downloads
+	// is reported.
+	Progress io.Writer
+	// Proxy lists the mirrors archives are downloaded from, in order, each
+	// one serving them in the ProxyLayout. The next mirror is tried only if
+	// the archive is not found. The ProxyDirect keyword stands for the project's
+	// host and ProxyOff disallows downloading from the following mirrors.
+	// If empty, archives are downloaded from the projects' hosts. The URLs
+	// recorded in lock entries are always the hosts' ones.
+	Proxy []string
+}
+
+// InstallRoot gives the workspace libraries are installed into. Unless
+// the Fetcher has the Root set, it's the first workspace of the lookup order:
+// the one set by the InstallRootEnv, the one the current directory is within
+// or the first $GOPATH one.
+func (f *Fetcher) InstallRoot() (string, error) {
+	if f.Root != "" {
+		return f.Root, nil
+	}
+	if len(defaultGopath) == 0 {
+		return "", errors.New("$GOPATH is empty")
+	}
+	return defaultGopath[0], nil
+}
+
+// lookupGopath looks up the package in the install root first and then
+// in the other workspaces.
+func (f *Fetcher) lookupGopath(root, pkg string) (*PC, error) {
+	return lookupGopath(withRoot(defaultGopath, root), pkg)
+}
+
+// cached gives the cached archive of the lock entry and its checksum, if any.
+// Unless the checksum is known from the lock entry or the sum file, the archive
+// last downloaded from the url may be stale, so it is used only if downloading
+// is disabled.
+func (f *Fetcher) cached(e *LockEntry) (string, string, bool) {
+	if f.Cache == nil {
+		return "", "", false
+	}
+	sum := e.Sum
+	if sum == "" && f.SumFile != "" {
+		// A malformed sum file is reported when the archive is verified.
+		if sums, err := readSums(f.SumFile); err == nil {
+			sum = sums[sumkey(e.Proj, e.Lib)]
+		}
+	}
+	if sum == "" && !f.Offline && f.source(e) != "" {
+		return "", "", false
+	}
+	return f.Cache.lookup(e.URL, sum)
+}
+
+// DefaultFetcher is the Fetcher used by LookupGithub and LookupGithubProj.
+// Its SumFile and KeysFile are the cdeps.sum and cdeps.keys files found
+// in the current working directory or any of its parents, or if there are
+// none, the ones in the root directory of the current project. It caches
+// archives in the DefaultCacheDir and works offline if PKG_CONFIG_OFFLINE=1
+// is exported. It authenticates with the tokens given by EnvToken and
+// the credentials from the DefaultNetrc file. It retries transient failures
+// DefaultRetries times and downloads archives through the mirrors listed
+// in the ProxyEnv environment variable.
+var DefaultFetcher = &Fetcher{Retries: DefaultRetries}
+
+// URL gives a location of the zip archive for the given package and project.
+// The package is either a library name, which is downloaded from the release
+// tagged with DefaultTag, or a LIB@TAG name. It returns empty string if no
+// host serves the project.
+func (f *Fetcher) URL(pkg, proj string) string {
+	urls, err := f.urls(pkg, proj)
+	if err != nil {
+		return ""
+	}
+	return urls[0]
+}
+
+func (f *Fetcher) hosts() Hosts {
+	if f.Hosts != nil {
+		return f.Hosts
+	}
+	return DefaultHosts
+}
+
+// urls gives locations of the archive for each of the ArchiveExts.
+func (f *Fetcher) urls(pkg, proj string) ([]string, error) {
+	host, ok := f.hosts().Match(proj)
+	if !ok {
+		return nil, fmt.Errorf("no known host serves %s", proj)
 	}
-	if path == "" {
-		return nil, errors.New("$GOPATH is empty")
+	base := f.BaseURL
+	if base == "" {
+		base = "https://"
+	}
+	name, tag := splittag(pkg)
+	if tag == "" {
+		tag = DefaultTag
+	}
+	urls := make([]string, 0, len(ArchiveExts))
+	for _, ext := range ArchiveExts {
+		url := host.URL(base, proj, tag, name, ext)
+		// A template with no {ext} gives the same URL for each extension.
+		if len(urls) != 0 && urls[len(urls)-1] == url {
+			continue
+		}
+		urls = append(urls, url)
+	}
+	return urls, nil
+}
+
+var errInsecureRedirect = errors.New("refusing to follow redirect from https to http")
+
+func (f *Fetcher) client() *http.Client {
+	c := http.DefaultClient
+	if f.Client != nil {
+		c = f.Client
 	}
-	url := fmt.Sprintf("http://%s/releases/download/pkg-config/%s.zip", proj, pkg)
-	res, err := http.Get(url)
+	// Copy the client in order to not leak the redirect policy outside.
+	client := *c
+	if f.Token != nil || f.Netrc != "" {
+		t := &authTransport{base: c.Transport, token: f.Token}
+		if t.base == nil {
+			t.base = http.DefaultTransport
+		}
+		if f.Netrc != "" {
+			// A missing or malformed netrc file means no credentials.
+			t.logins, _ = readNetrc(f.Netrc)
+		}
+		client.Transport = t
+	}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if req.URL.Scheme != "https" && via[len(via)-1].URL.Scheme == "https" {
+			return errInsecureRedirect
+		}
+		if c.CheckRedirect != nil {
+			return c.CheckRedirect(req, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	return &client
+}
+
+type notFoundError string
+
+func (e notFoundError) Error() string {
+	return "not found: " + string(e)
+}
+
+// httpGet issues a GET request for the url, which is cancelled when
+// the context is done.
+func (f *Fetcher) httpGet(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
-	defer res.Body.Close()
-	if res.StatusCode == http.StatusNotFound {
-		return nil, errors.New("not found: " + url)
+	return f.client().Do(req)
+}
+
+// Get downloads the archive for the given package from the project's
+// releases, verifies its checksum and signature and unpacks it into
+// the InstallRoot workspace. A LIB@TAG package is downloaded from the release with
+// the given tag and unpacked into its own, version-qualified directory given
+// by TagRoot, so installing another version never overwrites the current one.
+// The returned lock entry describes what was installed.
+//
+// The package is locked for the time of the installation, so concurrent
+// fetches of the same package, also by other processes, are serialized.
+func (f *Fetcher) Get(pkg, proj string) (*LockEntry, error) {
+	return f.GetContext(context.Background(), pkg, proj)
+}
+
+// GetContext is Get, which cancels the downloads when the context is done.
+func (f *Fetcher) GetContext(ctx context.Context, pkg, proj string) (*LockEntry, error) {
+	path, err := f.InstallRoot()
+	if err != nil {
+		return nil, err
 	}
-	f, err := ioutil.TempFile("", pkg)
+	unlock, err := lockLib(path, pkg)
 	if err != nil {
 		return nil, err
 	}
-	if _, err = io.Copy(f, res.Body); err != nil {
-		f.Close()
-		os.Remove(f.Name())
+	defer unlock()
+	e, _, err := f.get(ctx, path, pkg, proj, false)
+	return e, err
+}
+
+// get fetches the package from the first of its urls, which exists. It gives
+// the requirements of the package listed in the archive's dependency file.
+func (f *Fetcher) get(ctx context.Context, path, pkg, proj string, dry bool) (*LockEntry, []require, error) {
+	urls, err := f.urls(pkg, proj)
+	if err != nil {
+		return nil, nil, err
+	}
+	var notfound error
+	// Archives of all the supported formats are tried in order.
+	for _, url := range urls {
+		e := &LockEntry{Lib: pkg, Proj: proj, URL: url}
+		reqs, err := f.fetchLocked(ctx, path, e, dry)
+		if err == nil {
+			return e, reqs, nil
+		}
+		if _, ok := err.(notFoundError); !ok {
+			return nil, nil, err
+		}
+		if notfound == nil {
+			notfound = err
+		}
+	}
+	return nil, nil, notfound
+}
+
+// fetch installs the package described by the lock entry. If the entry has
+// no checksum, it's set to the one of the downloaded archive; otherwise
+// the archive must match it.
+func (f *Fetcher) fetch(ctx context.Context, e *LockEntry) error {
+	path, err := f.InstallRoot()
+	if err != nil {
+		return err
+	}
+	unlock, err := lockLib(path, e.Lib)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	_, err = f.fetchLocked(ctx, path, e, false)
+	return err
+}
+
+// fetchLocked is fetch, which expects the caller to hold the lock for
+// the library. It gives the requirements of the package listed in the archive's
+// dependency file. If dry is true, the archive is verified but not installed.
+func (f *Fetcher) fetchLocked(ctx context.Context, path string, e *LockEntry, dry bool) ([]require, error) {
+	gopath := path
+	name, tag := splittag(e.Lib)
+	if name != e.Lib {
+		if !validTag(tag) {
+			return nil, fmt.Errorf("invalid tag in %q", e.Lib)
+		}
+		path = TagRoot(path, e.Lib)
+	} else {
+		tag = DefaultTag
+	}
+	if e.Tag == "" {
+		e.Tag = tag
+	}
+	var (
+		record bool
+		err    error
+		src    string
+	)
+	file, sum, cached := f.cached(e)
+	if cached {
+		// A missing signature is fetched from the first mirror.
+		src = f.source(e)
+		// The cached archive was verified against the sibling checksum
+		// when it was downloaded.
+		if record, err = f.verifySumFile(sum, e.URL, e.Lib, e.Proj); err != nil {
+			return nil, err
+		}
+	} else {
+		if f.Offline {
+			return nil, notFoundError(e.URL + " (not cached, offline mode)")
+		}
+		if file, src, err = f.downloadProxy(ctx, e, name); err != nil {
+			return nil, err
+		}
+		defer os.Remove(file)
+		if sum, record, err = f.verify(ctx, file, src, e.Lib, e.Proj); err != nil {
+			return nil, err
+		}
+	}
+	if e.Sum != "" && e.Sum != sum {
+		return nil, &ChecksumError{URL: e.URL, Source: "lock file", Expected: e.Sum, Actual: sum}
+	}
+	if err = f.verifySig(ctx, file, src, e.Proj, sum); err != nil {
 		return nil, err
 	}
-	f.Close()
-	r, err := zip.OpenReader(f.Name())
+	if !cached && f.Cache != nil {
+		// Failing to cache the archive does not fail the installation.
+		f.Cache.put(e.URL, file, sum)
+	}
+	reqs, err := archiveRequires(file, name)
+	if err != nil {
+		return nil, err
+	}
+	e.Sum = sum
+	if dry {
+		return reqs, nil
+	}
+	if err = install(path, file, name); err != nil {
+		return nil, err
+	}
+	if err = writeManifest(gopath, e, file); err != nil {
+		return nil, err
+	}
+	if record {
+		if err = appendSum(f.SumFile, e.Proj, e.Lib, sum); err != nil {
+			return nil, err
+		}
+	}
+	return reqs, nil
+}
+
+// Lookup installs the given package together with its missing requirements
+// and looks it up in $GOPATH. If the package was installed by another process
+// while waiting for the lock, it's not downloaded again.
+func (f *Fetcher) Lookup(pkg, proj string) (*PC, error) {
+	return f.LookupContext(context.Background(), pkg, proj)
+}
+
+// LookupContext is Lookup, which cancels the downloads when the context
+// is done.
+func (f *Fetcher) LookupContext(ctx context.Context, pkg, proj string) (*PC, error) {
+	steps, err := f.getAll(ctx, pkg, proj, false, true)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		r.Close()
-		os.Remove(f.Name())
-	}()
-	for _, f := range r.File {
-		// Filter out directories.
-		if f.Name[len(f.Name)-1] != '/' {
-			if !validFile(f.Name, pkg) {
-				return nil, fmt.Errorf("unexcpected file %q", f.Name)
-			}
-			if err = copyFile(path, f); err != nil {
-				return nil, err
-			}
-		}
-	}
-	return LookupGopath(pkg)
+	return f.lookupGopath(steps[0].Root, pkg)
+}
+
+// Sync installs exactly the packages listed in the lock file, downloading
+// each one from its locked URL. It fails if any archive does not match its
+// locked checksum.
+func (f *Fetcher) Sync(l *LockFile) error {
+	return f.SyncContext(context.Background(), l)
+}
+
+// SyncContext is Sync, which stops as soon as the context is done.
+func (f *Fetcher) SyncContext(ctx context.Context, l *LockFile) error {
+	for _, e := range l.Entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if e.Sum == "" {
+			return fmt.Errorf("%s: no checksum locked for %s", l.File, e.Lib)
+		}
+		if err := f.fetch(ctx, &e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LookupGithubProj TODO(rjeczalik): document
+func LookupGithubProj(pkg, proj string) (*PC, error) {
+	return LookupGithubProjContext(context.Background(), pkg, proj)
+}
+
+// LookupGithubProjContext is LookupGithubProj, which cancels the downloads
+// when the context is done.
+func LookupGithubProjContext(ctx context.Context, pkg, proj string) (*PC, error) {
+	return DefaultFetcher.LookupContext(ctx, pkg, proj)
 }
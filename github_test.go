@@ -1,6 +1,23 @@
 package pkgconfig
 
-import "testing"
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
 
 func TestExtractProj(t *testing.T) {
 	cases := []struct {
@@ -42,7 +59,16 @@ func TestExtractProj(t *testing.T) {
 			'\\': `.\src\codeplex.com\rjeczalik\casablanca`,
 		},
 		"",
+	}, {
+		map[rune]string{
+			'/':  "/home/rjeczalik/src/git.example.com/rjeczalik/pkgconfig/cmd",
+			'\\': `C:\Users\rjeczalik\src\git.example.com\rjeczalik\pkgconfig\cmd`,
+		},
+		"git.example.com/rjeczalik/pkgconfig",
 	}}
+	old := DefaultHosts
+	DefaultHosts = append(Hosts{{"git.example.com", Layouts["gitea"]}}, old...)
+	defer func() { DefaultHosts = old }()
 	for i, cas := range cases {
 		for _, sep := range []rune{'/', '\\'} {
 			if s := extractproj(cas.path[sep], sep); s != cas.exp {
@@ -114,6 +140,288 @@ func TestValidFile(t *testing.T) {
 	}
 }
 
+var target = runtime.GOOS + "_" + runtime.GOARCH
+
+func newzip(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+		if _, err = f.Write([]byte(content)); err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	return buf.Bytes()
+}
+
+func libfoozip(t *testing.T) []byte {
+	return newzip(t, map[string]string{
+		"include/libfoo/foo.h": "#define FOO 1\n",
+		"lib/" + target + "/libfoo/libfoo.pc": "libdir=${GOPATH}/lib/${GOOS}_${GOARCH}/libfoo\n" +
+			"includedir=${GOPATH}/include/libfoo\n\nName: libfoo\nVersion: 1.0\n" +
+			"Libs: -L${libdir} -lfoo\nCflags: -I${includedir}\n",
+	})
+}
+
+// tempgopath sets up a temporary $GOPATH, the returned function restores
+// the previous one.
+func tempgopath(t *testing.T) (string, func()) {
+	if _, ok := targets[target]; !ok {
+		t.Skipf("unsupported target %s", target)
+	}
+	dir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	old, oldGopath := os.Getenv("GOPATH"), defaultGopath
+	os.Setenv("GOPATH", dir)
+	defaultGopath = []string{dir}
+	return dir, func() {
+		os.Setenv("GOPATH", old)
+		defaultGopath = oldGopath
+		os.RemoveAll(dir)
+	}
+}
+
+const libfooPath = "/github.com/user/proj/releases/download/pkg-config/libfoo.zip"
+
+func TestFetcherURL(t *testing.T) {
+	cases := [...]struct {
+		f   *Fetcher
+		pkg string
+		exp string
+	}{{
+		&Fetcher{},
+		"libfoo",
+		"https://github.com/user/proj/releases/download/pkg-config/libfoo.zip",
+	}, {
+		&Fetcher{},
+		"libfoo@v1.2.0",
+		"https://github.com/user/proj/releases/download/v1.2.0/libfoo.zip",
+	}, {
+		&Fetcher{BaseURL: "http://127.0.0.1:8080/"},
+		"libfoo",
+		"http://127.0.0.1:8080/github.com/user/proj/releases/download/pkg-config/libfoo.zip",
+	}}
+	for i, cas := range cases {
+		if url := cas.f.URL(cas.pkg, "github.com/user/proj"); url != cas.exp {
+			t.Errorf("expected url=%q; was %q (i=%d)", cas.exp, url, i)
+		}
+	}
+}
+
 func TestLookupGithub(t *testing.T) {
+	dir, restore := tempgopath(t)
+	defer restore()
+	p := libfoozip(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/archive/libfoo.zip", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(p)
+	})
+	mux.HandleFunc(libfooPath, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/archive/libfoo.zip", http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	f := &Fetcher{Client: srv.Client(), BaseURL: srv.URL + "/"}
+	pc, err := f.Lookup("libfoo", "github.com/user/proj")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if pc.Name != "libfoo" || pc.Version != "1.0" {
+		t.Errorf("expected libfoo 1.0; was %s %s", pc.Name, pc.Version)
+	}
+	if exp := "-I" + filepath.Join(dir, "include", "libfoo"); len(pc.Cflags) != 1 || pc.Cflags[0] != exp {
+		t.Errorf("expected pc.Cflags=[%s]; was %v", exp, pc.Cflags)
+	}
+	if err = existFile(filepath.Join(dir, "include", "libfoo", "foo.h")); err != nil {
+		t.Errorf("expected err=nil; was %q", err)
+	}
+}
+
+func TestLookupGithubConcurrent(t *testing.T) {
+	_, restore := tempgopath(t)
+	defer restore()
+	p := libfoozip(t)
+	var n int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != libfooPath {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(&n, 1)
+		w.Write(p)
+	}))
+	defer srv.Close()
+	f := &Fetcher{Client: srv.Client(), BaseURL: srv.URL + "/"}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := f.Lookup("libfoo", "github.com/user/proj"); err != nil {
+				t.Errorf("expected err=nil; was %q (i=%d)", err, i)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&n); n != 1 {
+		t.Errorf("expected the archive to be downloaded once; was %d times", n)
+	}
+}
+
+func TestLookupGithubErr(t *testing.T) {
+	dir, restore := tempgopath(t)
+	defer restore()
+	p := libfoozip(t)
+	handlers := [...]http.HandlerFunc{
+		func(w http.ResponseWriter, r *http.Request) {
+			http.NotFound(w, r)
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", strconv.Itoa(len(p)))
+			w.Write(p[:len(p)/2])
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("<html>not a zip</html>"))
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/nonexistent", http.StatusMovedPermanently)
+		},
+	}
+	for i, handler := range handlers {
+		srv := httptest.NewServer(handler)
+		f := &Fetcher{Client: srv.Client(), BaseURL: srv.URL + "/"}
+		if _, err := f.Lookup("libfoo", "github.com/user/proj"); err == nil {
+			t.Errorf("expected err!=nil (i=%d)", i)
+		}
+		srv.Close()
+		if err := existDir(filepath.Join(dir, "include")); err == nil {
+			t.Errorf("expected nothing to be installed (i=%d)", i)
+		}
+	}
+}
+
+func TestLookupGithubInsecureRedirect(t *testing.T) {
+	_, restore := tempgopath(t)
+	defer restore()
+	p := libfoozip(t)
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(p)
+	}))
+	defer plain.Close()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, plain.URL+r.URL.Path, http.StatusFound)
+	}))
+	defer srv.Close()
+	f := &Fetcher{Client: srv.Client(), BaseURL: srv.URL + "/"}
+	_, err := f.Lookup("libfoo", "github.com/user/proj")
+	if err == nil || !strings.Contains(err.Error(), errInsecureRedirect.Error()) {
+		t.Errorf("expected err=%q; was %v", errInsecureRedirect, err)
+	}
+}
+
+func TestLookupGithubContext(t *testing.T) {
+	dir, restore := tempgopath(t)
+	defer restore()
+	p := libfoozip(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(p)))
+		w.Write(p[:len(p)/2])
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	f := &Fetcher{Client: srv.Client(), BaseURL: srv.URL + "/"}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := f.LookupContext(ctx, "libfoo", "github.com/user/proj")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected err=%q; was %v", context.DeadlineExceeded, err)
+	}
+	if err = existDir(filepath.Join(dir, "include")); err == nil {
+		t.Error("expected nothing to be installed")
+	}
+	if _, err = f.GetContext(ctx, "libfoo", "github.com/user/proj"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected err=%q; was %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestLookupGithubTag(t *testing.T) {
+	dir, restore := tempgopath(t)
+	defer restore()
+	p := libfoozip(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/github.com/user/proj/releases/download/v1.2.0/libfoo.zip", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(p)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	f := &Fetcher{Client: srv.Client(), BaseURL: srv.URL + "/"}
+	pc, err := f.Lookup("libfoo@v1.2.0", "github.com/user/proj")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	root := TagRoot(dir, "libfoo@v1.2.0")
+	if exp := "-I" + filepath.Join(root, "include", "libfoo"); len(pc.Cflags) != 1 || pc.Cflags[0] != exp {
+		t.Errorf("expected pc.Cflags=[%s]; was %v", exp, pc.Cflags)
+	}
+	if err = existDir(filepath.Join(dir, "include")); err == nil {
+		t.Error("expected the unversioned location to be left intact")
+	}
+	for i, pkg := range []string{"libfoo@v1.3.0", "libfoo@", "libfoo@..", "libfoo@v1/../../x"} {
+		if _, err := f.Lookup(pkg, "github.com/user/proj"); err == nil {
+			t.Errorf("expected err!=nil (i=%d)", i)
+		}
+	}
+}
 
+func TestFetcherInstallRoot(t *testing.T) {
+	dir, restore := tempgopath(t)
+	defer restore()
+	root, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	defer os.RemoveAll(root)
+	p := libfoozip(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != libfooPath {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(p)
+	}))
+	defer srv.Close()
+	f := &Fetcher{Client: srv.Client(), BaseURL: srv.URL + "/"}
+	if path, err := f.InstallRoot(); err != nil || path != dir {
+		t.Errorf("expected root=%q; was %q (err=%v)", dir, path, err)
+	}
+	f.Root = root
+	pc, err := f.Lookup("libfoo", "github.com/user/proj")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if exp := "-I" + filepath.Join(root, "include", "libfoo"); len(pc.Cflags) != 1 || pc.Cflags[0] != exp {
+		t.Errorf("expected pc.Cflags=[%s]; was %v", exp, pc.Cflags)
+	}
+	if err = existDir(filepath.Join(dir, "include")); err == nil {
+		t.Errorf("expected nothing to be installed into %s", dir)
+	}
+	steps, err := f.GetAll("libfoo", "github.com/user/proj", false)
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if len(steps) != 1 || steps[0].Root != root {
+		t.Errorf("expected libfoo to be installed into %s; was %+v", root, steps)
+	}
 }
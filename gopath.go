@@ -1,6 +1,7 @@
 package pkgconfig
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -39,34 +40,88 @@ func existFile(files ...string) (err error) {
 	return
 }
 
+// InstallRootEnv is the environment variable, which sets the workspace
+// libraries are installed into. The workspace goes first in the lookup order.
+const InstallRootEnv = "PKG_CONFIG_INSTALL_ROOT"
+
+// gopath gives the workspaces libraries are looked up in, in order: the one
+// set by the InstallRootEnv, the one the current directory is within and
+// the $GOPATH ones.
 func gopath(sep rune) []string {
 	var paths = strings.Split(os.Getenv("GOPATH"), string(sep))
 	if wd != "" {
-		if i := strings.Index(wd, src[sep]); i != -1 {
+		if i := strings.Index(wd, src[os.PathSeparator]); i != -1 {
 			tmp := make([]string, 0, len(paths)+1)
 			tmp = append(tmp, wd[:i])
 			paths = append(tmp, paths...)
 		}
 	}
-	for i := 1; i < len(paths); i++ {
-		if paths[i] == paths[0] {
-			paths = append(paths[:i], paths[i+1:]...)
-			break
+	if root := os.Getenv(InstallRootEnv); root != "" {
+		paths = append([]string{root}, paths...)
+	}
+	return withRoot(paths, "")
+}
+
+// withRoot puts the root first in the paths, removing duplicates and empty
+// entries.
+func withRoot(paths []string, root string) []string {
+	var (
+		seen = make(map[string]struct{}, len(paths)+1)
+		res  = make([]string, 0, len(paths)+1)
+	)
+	for _, path := range append([]string{root}, paths...) {
+		if _, ok := seen[path]; ok || path == "" {
+			continue
 		}
+		seen[path] = struct{}{}
+		res = append(res, path)
 	}
-	return paths
+	return res
 }
 
 // GopathLibrary TODO(rjeczalik): document
 func GopathLibrary(path, pkg string) (include, lib string) {
+	return gopathLibrary(path, pkg, runtime.GOOS, runtime.GOARCH)
+}
+
+func gopathLibrary(path, pkg, goos, goarch string) (include, lib string) {
 	include = filepath.Join(path, "include", pkg)
-	lib = filepath.Join(path, "lib", runtime.GOOS+"_"+runtime.GOARCH, pkg)
+	lib = filepath.Join(path, "lib", goos+"_"+goarch, pkg)
 	return
 }
 
-func walkgopath(pkg string, fn func(string, string, string) bool) bool {
-	for _, path := range defaultGopath {
-		include, lib := GopathLibrary(path, pkg)
+// DefaultTag is the release tag libraries are downloaded from, if no other
+// tag was requested.
+const DefaultTag = "pkg-config"
+
+// splittag splits a LIB@TAG package name into the library name and the tag.
+// The tag is empty if none was given.
+func splittag(pkg string) (name, tag string) {
+	if i := strings.LastIndex(pkg, "@"); i != -1 {
+		return pkg[:i], pkg[i+1:]
+	}
+	return pkg, ""
+}
+
+func validTag(tag string) bool {
+	return tag != "" && tag != "." && tag != ".." && !strings.ContainsAny(tag, `/\`)
+}
+
+// TagRoot gives the root directory of the given LIB@TAG package within
+// the $GOPATH workspace. The root has the same layout as the workspace
+// itself, with the include and lib directories, and when looking up the
+// package the ${GOPATH} variable is expanded to the root.
+func TagRoot(path, pkg string) string {
+	return filepath.Join(path, "pkg", "pkg-config", pkg)
+}
+
+func walkgopath(paths []string, pkg, goos, goarch string, fn func(string, string, string) bool) bool {
+	name, tag := splittag(pkg)
+	for _, path := range paths {
+		if tag != "" {
+			path = TagRoot(path, pkg)
+		}
+		include, lib := gopathLibrary(path, name, goos, goarch)
 		if existDir(include, lib) != nil {
 			continue
 		}
@@ -79,14 +134,30 @@ func walkgopath(pkg string, fn func(string, string, string) bool) bool {
 
 // LookupGopath TODO(rjeczalik): document
 func LookupGopath(pkg string) (*PC, error) {
+	return lookupGopath(defaultGopath, pkg)
+}
+
+// LookupGopathContext is LookupGopath, which fails if the context is done.
+func LookupGopathContext(ctx context.Context, pkg string) (*PC, error) {
+	return withContext(LookupGopath)(ctx, pkg)
+}
+
+func lookupGopath(paths []string, pkg string) (*PC, error) {
+	return lookupGopathTarget(paths, pkg, runtime.GOOS, runtime.GOARCH)
+}
+
+// lookupGopathTarget is lookupGopath, which looks up the package built
+// for the given target.
+func lookupGopathTarget(paths []string, pkg, goos, goarch string) (*PC, error) {
 	var (
-		vars = map[string]string{"GOOS": runtime.GOOS, "GOARCH": runtime.GOARCH}
+		vars = map[string]string{"GOOS": goos, "GOARCH": goarch}
 		err  error
 		pc   *PC
 		f    *os.File
 	)
+	name, _ := splittag(pkg)
 	look := func(path, _, lib string) bool {
-		file := filepath.Join(lib, pkg+".pc")
+		file := filepath.Join(lib, name+".pc")
 		vars["GOPATH"] = path
 		if f, err = os.Open(file); err == nil {
 			pc, err = NewPCVars(f, vars)
@@ -98,7 +169,7 @@ func LookupGopath(pkg string) (*PC, error) {
 		}
 		return true
 	}
-	if !walkgopath(pkg, look) {
+	if !walkgopath(paths, pkg, goos, goarch, look) {
 		if err != nil {
 			return nil, err
 		}
@@ -110,18 +181,19 @@ func LookupGopath(pkg string) (*PC, error) {
 // GenerateGopath TODO(rjeczalik): document
 func GenerateGopath(pkg string) (*PC, error) {
 	var pc *PC
+	name, _ := splittag(pkg)
 	gen := func(path, include, lib string) bool {
 		pc = &PC{
 			Libs: []string{
 				"-L" + lib,
-				"-l" + strings.TrimLeft(pkg, "lib"),
+				"-l" + strings.TrimLeft(name, "lib"),
 				"-Wl,-rpath", "-Wl,$ORIGIN",
 			},
 			Cflags: []string{"-I" + include},
 		}
 		return false
 	}
-	if !walkgopath(pkg, gen) {
+	if !walkgopath(defaultGopath, pkg, runtime.GOOS, runtime.GOARCH, gen) {
 		return nil, errors.New("no library found in $GOPATH: " + pkg)
 	}
 	return pc, nil
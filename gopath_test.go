@@ -1,8 +1,10 @@
 package pkgconfig
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -35,3 +37,104 @@ func TestLookupGopath(t *testing.T) {
 		t.Errorf("expected len(pc.Cflags)!=0")
 	}
 }
+
+func TestSplitTag(t *testing.T) {
+	cases := [...]struct {
+		pkg, name, tag string
+	}{
+		{"libgit2", "libgit2", ""},
+		{"libgit2@v0.20.0", "libgit2", "v0.20.0"},
+		{"libgit2@", "libgit2", ""},
+		{"lib@foo@v1", "lib@foo", "v1"},
+	}
+	for i, cas := range cases {
+		if name, tag := splittag(cas.pkg); name != cas.name || tag != cas.tag {
+			t.Errorf("expected name=%q, tag=%q; was %q, %q (i=%d)", cas.name, cas.tag, name, tag, i)
+		}
+	}
+	for i, tag := range []string{"", ".", "..", "v1/../..", `v1\x`} {
+		if validTag(tag) {
+			t.Errorf("expected tag=%q to be invalid (i=%d)", tag, i)
+		}
+	}
+}
+
+func TestLookupGopathTag(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	dir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	defer os.RemoveAll(dir)
+	old := defaultGopath
+	defer func() { defaultGopath = old }()
+	defaultGopath = []string{filepath.Join(wd, "testdata"), dir}
+	root := TagRoot(dir, "libgit2@v0.21.0")
+	include, lib := GopathLibrary(root, "libgit2")
+	for _, d := range []string{include, lib} {
+		if err = os.MkdirAll(d, 0755); err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+	}
+	pc := []byte("libdir=${GOPATH}/lib/${GOOS}_${GOARCH}/libgit2\n\nName: libgit2\n" +
+		"Version: 0.21.0\nLibs: -L${libdir} -lgit2\n")
+	if err = ioutil.WriteFile(filepath.Join(lib, "libgit2.pc"), pc, 0644); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	cases := [...]struct {
+		pkg, version string
+	}{
+		{"libgit2", "0.20.0"},
+		{"libgit2@v0.21.0", "0.21.0"},
+	}
+	for i, cas := range cases {
+		pc, err := LookupGopath(cas.pkg)
+		if err != nil {
+			t.Errorf("expected err=nil; was %q (i=%d)", err, i)
+			continue
+		}
+		if pc.Version != cas.version {
+			t.Errorf("expected pc.Version=%q; was %q (i=%d)", cas.version, pc.Version, i)
+		}
+	}
+	if pc, err := LookupGopath("libgit2@v0.21.0"); err != nil || pc.Libs[0] != "-L"+lib {
+		t.Errorf("expected pc.Libs[0]=%q; was %+v (err=%v)", "-L"+lib, pc, err)
+	}
+	if _, err = LookupGopath("libgit2@v0.22.0"); err == nil {
+		t.Error("expected err!=nil")
+	}
+}
+
+func TestGopathOrder(t *testing.T) {
+	oldwd, oldpath, oldroot := wd, os.Getenv("GOPATH"), os.Getenv(InstallRootEnv)
+	defer func() {
+		wd = oldwd
+		os.Setenv("GOPATH", oldpath)
+		os.Setenv(InstallRootEnv, oldroot)
+	}()
+	sep := string(os.PathListSeparator)
+	cases := [...]struct {
+		wd, gopath, root string
+		exp              []string
+	}{
+		{"", "/a" + sep + "/b", "", []string{"/a", "/b"}},
+		{"/w/src/github.com/user/proj", "/a" + sep + "/b", "", []string{"/w", "/a", "/b"}},
+		{"/w/src/github.com/user/proj", "/a" + sep + "/w", "/b", []string{"/b", "/w", "/a"}},
+		{"", "/a" + sep + sep + "/a", "/a", []string{"/a"}},
+		{"", "", "", []string{}},
+	}
+	if os.PathSeparator != '/' {
+		t.Skip("the cases use slash-separated paths")
+	}
+	for i, cas := range cases {
+		wd = cas.wd
+		os.Setenv("GOPATH", cas.gopath)
+		os.Setenv(InstallRootEnv, cas.root)
+		if paths := gopath(os.PathListSeparator); !reflect.DeepEqual(paths, cas.exp) {
+			t.Errorf("expected paths=%v; was %v (i=%d)", cas.exp, paths, i)
+		}
+	}
+}
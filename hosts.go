@@ -0,0 +1,111 @@
+package pkgconfig
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// HostsFileName is the name of a file, committed in a consuming repository,
+// which registers additional hosts serving library archives. Each line maps
+// a project path prefix either to one of the Layouts or to a URL template:
+//
+//	gitea.example.com gitea
+//	example.com/mirror https://artifacts.example.com/cdeps/{proj}/{tag}/{lib}{ext}
+const HostsFileName = "cdeps.hosts"
+
+// Layouts are URL templates of release archives of the supported hosting
+// services. Within a template the following placeholders are expanded:
+//
+//   - {base} - the Fetcher's BaseURL, https:// by default
+//   - {proj} - the project path, e.g. github.com/USER/PROJECT
+//   - {tag}  - the release tag, DefaultTag unless LIB@TAG is requested
+//   - {lib}  - the library name
+//   - {ext}  - the archive extension, one of the ArchiveExts
+var Layouts = map[string]string{
+	"github": "{base}{proj}/releases/download/{tag}/{lib}{ext}",
+	"gitlab": "{base}{proj}/-/releases/{tag}/downloads/{lib}{ext}",
+	"gitea":  "{base}{proj}/releases/download/{tag}/{lib}{ext}",
+}
+
+// Host maps projects, which paths start with the Prefix, to the URL template
+// of their release archives.
+type Host struct {
+	Prefix   string
+	Template string
+}
+
+// Hosts is a registry of artifact hosts.
+type Hosts []Host
+
+// DefaultHosts is the registry of artifact hosts used by a Fetcher with no
+// Hosts. It's extended by the hosts read from the cdeps.hosts file found
+// in the current working directory or any of its parents.
+var DefaultHosts = Hosts{
+	{"github.com", Layouts["github"]},
+	{"gitlab.com", Layouts["gitlab"]},
+	{"gitea.com", Layouts["gitea"]},
+	{"codeberg.org", Layouts["gitea"]},
+}
+
+// Match gives the host serving the archives of the given project. If more
+// than one host matches, the one with the longest prefix wins, on a tie
+// the first one.
+func (h Hosts) Match(proj string) (Host, bool) {
+	var (
+		host Host
+		ok   bool
+	)
+	for _, c := range h {
+		if proj != c.Prefix && !strings.HasPrefix(proj, strings.TrimSuffix(c.Prefix, "/")+"/") {
+			continue
+		}
+		if !ok || len(c.Prefix) > len(host.Prefix) {
+			host, ok = c, true
+		}
+	}
+	return host, ok
+}
+
+// URL expands the template of the host.
+func (h Host) URL(base, proj, tag, lib, ext string) string {
+	return strings.NewReplacer(
+		"{base}", base,
+		"{proj}", proj,
+		"{tag}", tag,
+		"{lib}", lib,
+		"{ext}", ext,
+	).Replace(h.Template)
+}
+
+// ReadHostsFile reads the hosts from the given cdeps.hosts file.
+func ReadHostsFile(file string) (Hosts, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var (
+		hosts Hosts
+		buf   = bufio.NewScanner(f)
+	)
+	for n := 1; buf.Scan(); n++ {
+		v := strings.Fields(buf.Text())
+		if len(v) == 0 || strings.HasPrefix(v[0], "#") {
+			continue
+		}
+		if len(v) != 2 {
+			return nil, fmt.Errorf("%s:%d: malformed line", file, n)
+		}
+		tmpl, ok := Layouts[v[1]]
+		if !ok {
+			if !strings.Contains(v[1], "{lib}") {
+				return nil, fmt.Errorf("%s:%d: unknown layout or template %q", file, n, v[1])
+			}
+			tmpl = v[1]
+		}
+		hosts = append(hosts, Host{Prefix: v[0], Template: tmpl})
+	}
+	return hosts, buf.Err()
+}
@@ -0,0 +1,135 @@
+package pkgconfig
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHostsMatch(t *testing.T) {
+	hosts := Hosts{
+		{"example.com/mirror", "mirror"},
+		{"example.com", "example"},
+		{"github.com", "first"},
+		{"github.com", "second"},
+	}
+	cases := [...]struct {
+		proj string
+		exp  string
+	}{
+		{"github.com/user/proj", "first"},
+		{"example.com/user/proj", "example"},
+		{"example.com/mirror/proj", "mirror"},
+		{"example.com/mirrors/proj", "example"},
+		{"github.community/user/proj", ""},
+		{"bitbucket.org/user/proj", ""},
+	}
+	for i, cas := range cases {
+		host, ok := hosts.Match(cas.proj)
+		if ok != (cas.exp != "") || host.Template != cas.exp {
+			t.Errorf("expected template=%q; was %q (ok=%v, i=%d)", cas.exp, host.Template, ok, i)
+		}
+	}
+}
+
+func TestFetcherURLHosts(t *testing.T) {
+	f := &Fetcher{Hosts: append(Hosts{
+		{"git.example.com", Layouts["gitea"]},
+		{"example.com/mirror", "http://artifacts.example.com/{proj}/{tag}/{lib}.zip"},
+	}, DefaultHosts...)}
+	cases := [...]struct {
+		pkg, proj string
+		exp       string
+	}{{
+		"libfoo", "gitlab.com/user/proj",
+		"https://gitlab.com/user/proj/-/releases/pkg-config/downloads/libfoo.zip",
+	}, {
+		"libfoo@v1.0", "git.example.com/user/proj",
+		"https://git.example.com/user/proj/releases/download/v1.0/libfoo.zip",
+	}, {
+		"libfoo", "example.com/mirror/proj",
+		"http://artifacts.example.com/example.com/mirror/proj/pkg-config/libfoo.zip",
+	}, {
+		"libfoo", "bitbucket.org/user/proj",
+		"",
+	}}
+	for i, cas := range cases {
+		if url := f.URL(cas.pkg, cas.proj); url != cas.exp {
+			t.Errorf("expected url=%q; was %q (i=%d)", cas.exp, url, i)
+		}
+	}
+	if urls, err := f.urls("libfoo", "example.com/mirror/proj"); err != nil || len(urls) != 1 {
+		t.Errorf("expected one url; was %v (err=%v)", urls, err)
+	}
+}
+
+func TestReadHostsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	defer os.RemoveAll(dir)
+	cases := [...]struct {
+		content string
+		exp     Hosts
+		ok      bool
+	}{{
+		"# comment\n\ngit.example.com gitea\nexample.com/mirror http://mirror/{proj}/{lib}{ext}\n",
+		Hosts{
+			{"git.example.com", Layouts["gitea"]},
+			{"example.com/mirror", "http://mirror/{proj}/{lib}{ext}"},
+		},
+		true,
+	}, {
+		"git.example.com\n", nil, false,
+	}, {
+		"git.example.com bitbucket\n", nil, false,
+	}}
+	for i, cas := range cases {
+		file := filepath.Join(dir, HostsFileName)
+		if err = ioutil.WriteFile(file, []byte(cas.content), 0644); err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+		hosts, err := ReadHostsFile(file)
+		if (err == nil) != cas.ok {
+			t.Errorf("expected ok=%v; was err=%v (i=%d)", cas.ok, err, i)
+			continue
+		}
+		if cas.ok && !reflect.DeepEqual(hosts, cas.exp) {
+			t.Errorf("expected hosts=%v; was %v (i=%d)", cas.exp, hosts, i)
+		}
+	}
+}
+
+func TestLookupGitea(t *testing.T) {
+	dir, restore := tempgopath(t)
+	defer restore()
+	p := libfoozip(t)
+	const path = "/git.example.com/user/proj/releases/download/pkg-config/libfoo.zip"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(p)
+	}))
+	defer srv.Close()
+	f := &Fetcher{
+		Client: srv.Client(),
+		Hosts:  Hosts{{"git.example.com", strings.Replace(Layouts["gitea"], "{base}", srv.URL+"/", 1)}},
+	}
+	if _, err := f.Lookup("libfoo", "github.com/user/proj"); err == nil {
+		t.Error("expected err!=nil")
+	}
+	if _, err := f.Lookup("libfoo", "git.example.com/user/proj"); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if err := existFile(filepath.Join(dir, "include", "libfoo", "foo.h")); err != nil {
+		t.Errorf("expected err=nil; was %q", err)
+	}
+}
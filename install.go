@@ -0,0 +1,249 @@
+package pkgconfig
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// libdir gives the library directory a valid archive file belongs to, which is
+// either include/LIB or lib/TARGET/LIB.
+func libdir(name string) string {
+	n := 2
+	if strings.HasPrefix(name, "lib/") {
+		n = 3
+	}
+	return path.Join(strings.SplitN(name, "/", n+1)[:n]...)
+}
+
+// validPkg reports whether the package name, LIB or LIB@TAG, is safe to be
+// used as a file name.
+func validPkg(pkg string) bool {
+	name, tag := splittag(pkg)
+	return validTag(name) && (name == pkg || validTag(tag)) && !strings.ContainsAny(pkg, ":\x00")
+}
+
+// safeName reports whether the slash-separated name of an archive entry is
+// a relative path, which stays within the directory it's unpacked into.
+func safeName(name string) bool {
+	if name == "" || path.IsAbs(name) || strings.ContainsAny(name, `\:`+"\x00") {
+		return false
+	}
+	for _, s := range strings.Split(name, "/") {
+		if s == "" || s == "." || s == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// archiveLimits protect against archive bombs.
+type archiveLimits struct {
+	files int   // maximum number of entries
+	size  int64 // maximum total size of the unpacked files
+	ratio int64 // maximum ratio of the unpacked size to the archive size
+	// minRatioSize is the unpacked size, below which the ratio is not checked,
+	// as tiny or empty files compress very well.
+	minRatioSize int64
+}
+
+var limits = archiveLimits{
+	files:        10000,
+	size:         1 << 30,
+	ratio:        100,
+	minRatioSize: 1 << 20,
+}
+
+var errTooLarge = errors.New("archive exceeds the limit of unpacked size")
+
+// validArchive checks all the entries of the archive, before anything is unpacked.
+// It gives the library directories the archive contains.
+func validArchive(file, pkg string) (dirs []string, err error) {
+	if !validPkg(pkg) {
+		return nil, fmt.Errorf("invalid package name %q", pkg)
+	}
+	fi, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		seen  = make(map[string]struct{})
+		names = make(map[string]struct{})
+		links = make(map[string]string)
+		files []string
+		n     int
+		size  int64
+	)
+	err = walkArchive(file, func(e *entry) error {
+		if n++; n > limits.files {
+			return fmt.Errorf("archive has more than %d entries", limits.files)
+		}
+		if !safeName(strings.TrimSuffix(e.name, "/")) {
+			return fmt.Errorf("unsafe file name %q", e.name)
+		}
+		// Filter out directories.
+		if e.mode.IsDir() {
+			return nil
+		}
+		if !validFile(e.name, pkg) {
+			return fmt.Errorf("unexcpected file %q", e.name)
+		}
+		// Names which differ in case only collide on case-insensitive
+		// filesystems.
+		key := strings.ToLower(e.name)
+		if _, ok := names[key]; ok {
+			return fmt.Errorf("duplicate file %q", e.name)
+		}
+		names[key] = struct{}{}
+		files = append(files, e.name)
+		if e.mode&os.ModeSymlink != 0 {
+			if !validLink(e.name, e.link) {
+				return fmt.Errorf("symlink %q points outside of the install root: %q", e.name, e.link)
+			}
+			links[e.name] = e.link
+		}
+		if e.size < 0 || e.size > limits.size-size {
+			return errTooLarge
+		}
+		size += e.size
+		if dir := libdir(e.name); dir != "" {
+			if _, ok := seen[dir]; !ok {
+				seen[dir] = struct{}{}
+				dirs = append(dirs, dir)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if size > limits.minRatioSize && size/limits.ratio > fi.Size() {
+		return nil, fmt.Errorf("archive compression ratio exceeds %d", limits.ratio)
+	}
+	// Files must not be unpacked through symlinks, which could redirect them
+	// to another library.
+	for link := range links {
+		for _, name := range files {
+			if strings.HasPrefix(strings.ToLower(name), strings.ToLower(link)+"/") {
+				return nil, fmt.Errorf("file %q is within symlink %q", name, link)
+			}
+		}
+	}
+	// Symlink targets are checked lexically, which holds only if they do not
+	// go through other symlinks; a chain of them could lead out of the root.
+	// A target which is a symlink itself is fine, as its own target is checked.
+	lower := make(map[string]struct{}, len(links))
+	for name := range links {
+		lower[strings.ToLower(name)] = struct{}{}
+	}
+	for name, link := range links {
+		if via := linkVia(name, link, lower); via != "" {
+			return nil, fmt.Errorf("symlink %q points through symlink %q: %q", name, via, link)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// linkVia gives the symlink of the archive, which the target of the given
+// symlink goes through, or empty string if there's none. The links are
+// the lowercased names of the archive symlinks.
+func linkVia(name, link string, links map[string]struct{}) string {
+	p, v := path.Dir(name), strings.Split(link, "/")
+	for _, s := range v[:len(v)-1] {
+		if p = path.Join(p, s); p == "." || p == ".." || strings.HasPrefix(p, "../") {
+			continue
+		}
+		if _, ok := links[strings.ToLower(p)]; ok {
+			return p
+		}
+	}
+	return ""
+}
+
+// install unpacks the archive into the given $GOPATH workspace. The whole
+// archive is validated first and unpacked into a staging directory, then each
+// of the library directories is atomically renamed into place, replacing
+// the previous version. If any step fails, the previous version is restored.
+func install(path, file, pkg string) (err error) {
+	dirs, err := validArchive(file, pkg)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(path, 0755); err != nil {
+		return err
+	}
+	// The staging directory is created within the workspace, so the renames
+	// do not cross filesystem boundaries.
+	stage, err := ioutil.TempDir(path, ".pkg-config-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(stage)
+	var (
+		unpacked = filepath.Join(stage, "new")
+		backup   = filepath.Join(stage, "old")
+	)
+	// The sizes were checked already, but the content may not match them.
+	remaining := limits.size
+	err = walkArchive(file, func(e *entry) error {
+		if e.mode.IsDir() {
+			return nil
+		}
+		if e.r == nil {
+			return copyFile(unpacked, e)
+		}
+		r := &io.LimitedReader{R: e.r, N: remaining + 1}
+		e.r = r
+		if err := copyFile(unpacked, e); err != nil {
+			return err
+		}
+		if remaining = r.N - 1; remaining < 0 {
+			return errTooLarge
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	type move struct{ from, to string }
+	var done []move
+	rename := func(from, to string) error {
+		if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(from, to); err != nil {
+			return err
+		}
+		done = append(done, move{from, to})
+		return nil
+	}
+	defer func() {
+		if err != nil {
+			for i := len(done) - 1; i >= 0; i-- {
+				os.Rename(done[i].to, done[i].from)
+			}
+		}
+	}()
+	for _, dir := range dirs {
+		dir = filepath.FromSlash(dir)
+		dst := filepath.Join(path, dir)
+		if _, err = os.Lstat(dst); err == nil {
+			if err = rename(dst, filepath.Join(backup, dir)); err != nil {
+				return err
+			}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		if err = rename(filepath.Join(unpacked, dir), dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package pkgconfig
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInstallRollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "root")
+	lib := "lib/linux_amd64/libfoo/"
+	archives := []struct {
+		files map[string]string
+		ok    bool
+	}{
+		{map[string]string{
+			"include/libfoo/foo.h": "v1",
+			lib + "libfoo.pc":      "v1",
+			lib + "libfoo.so.1":    "v1",
+		}, true},
+		// The invalid entry sorts last, after the valid ones were read.
+		{map[string]string{
+			"include/libfoo/foo.h": "v2",
+			lib + "libfoo.pc":      "v2",
+			"lib/zzz":              "v2",
+		}, false},
+		{map[string]string{
+			"include/libfoo/foo.h": "v3",
+			lib + "libfoo.pc":      "v3",
+		}, true},
+	}
+	expected := []map[string]string{
+		{"include/libfoo/foo.h": "v1", lib + "libfoo.pc": "v1", lib + "libfoo.so.1": "v1"},
+		{"include/libfoo/foo.h": "v1", lib + "libfoo.pc": "v1", lib + "libfoo.so.1": "v1"},
+		{"include/libfoo/foo.h": "v3", lib + "libfoo.pc": "v3", lib + "libfoo.so.1": ""},
+	}
+	for i, a := range archives {
+		file := filepath.Join(dir, "libfoo.tar.gz")
+		if err = ioutil.WriteFile(file, newtargz(t, a.files, nil), 0644); err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+		if err = install(root, file, "libfoo"); (err == nil) != a.ok {
+			t.Errorf("expected ok=%v; was err=%v (i=%d)", a.ok, err, i)
+		}
+		for name, content := range expected[i] {
+			p, err := ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+			if content == "" {
+				if !os.IsNotExist(err) {
+					t.Errorf("expected %s to be removed; was err=%v (i=%d)", name, err, i)
+				}
+				continue
+			}
+			if err != nil || string(p) != content {
+				t.Errorf("expected %s=%q; was %q (err=%v, i=%d)", name, content, p, err, i)
+			}
+		}
+		fis, err := ioutil.ReadDir(root)
+		if err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+		for _, fi := range fis {
+			if fi.Name() != "include" && fi.Name() != "lib" {
+				t.Errorf("expected staging directory to be removed; was %q (i=%d)", fi.Name(), i)
+			}
+		}
+	}
+}
+
+func TestSafeName(t *testing.T) {
+	cases := map[string]bool{
+		"include/libfoo/foo.h":             true,
+		"lib/linux_amd64/libfoo/libfoo.pc": true,
+		"include/libfoo/..foo.h":           true,
+		"":                                 false,
+		"/include/libfoo/foo.h":            false,
+		"include/libfoo/../../../foo.h":    false,
+		"include/libfoo/./foo.h":           false,
+		"include//libfoo/foo.h":            false,
+		`include/libfoo/..\..\foo.h`:       false,
+		"include/libfoo/C:foo.h":           false,
+		"include/libfoo/foo.h\x00":         false,
+	}
+	for name, exp := range cases {
+		if ok := safeName(name); ok != exp {
+			t.Errorf("expected ok=%v; was %v (name=%q)", exp, ok, name)
+		}
+	}
+	for i, pkg := range []string{"", ".", "..", "../libfoo", "libfoo@..", `libfoo\x`, "libfoo@", "C:libfoo"} {
+		if validPkg(pkg) {
+			t.Errorf("expected pkg=%q to be invalid (i=%d)", pkg, i)
+		}
+	}
+}
+
+// newzipentries creates a zip archive with the given entries, in order,
+// allowing duplicates.
+func newzipentries(t testing.TB, names ...string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range names {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+		f.Write([]byte(name))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	return buf.Bytes()
+}
+
+// checkRoot fails if there are any files within the dir other than the given
+// archive file and the installed files of libfoo within the root.
+func checkRoot(t testing.TB, dir, root, file string) {
+	err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() || p == file {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if rel = filepath.ToSlash(rel); !validFile(rel, "libfoo") {
+			t.Errorf("unexpected file %s", p)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+}
+
+func TestInstallMalicious(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	defer os.RemoveAll(dir)
+	old := limits
+	defer func() { limits = old }()
+	limits = archiveLimits{files: 8, size: 1 << 16, ratio: 10, minRatioSize: 1 << 10}
+	h := "include/libfoo/foo.h"
+	var zeros bytes.Buffer
+	w := zip.NewWriter(&zeros)
+	fw, _ := w.Create(h)
+	fw.Write(make([]byte, 1<<15))
+	w.Close()
+	archives := [][]byte{
+		newzipentries(t, h, "include/libfoo/../../../evil.h"),
+		newzipentries(t, h, "include/libfoo/../libbar/bar.h"),
+		newzipentries(t, h, "/include/libfoo/foo.h"),
+		newzipentries(t, h, `include/libfoo/..\..\..\evil.h`),
+		newzipentries(t, h, "include/libfoo/./foo.h"),
+		newzipentries(t, h, h),
+		newzipentries(t, h, "include/libfoo/FOO.h"),
+		newzipentries(t, h, h+"1", h+"2", h+"3", h+"4", h+"5", h+"6", h+"7", h+"8"),
+		newtargz(t, map[string]string{h: strings.Repeat("x", 1<<16+1)}, nil),
+		zeros.Bytes(),
+		newtargz(t, map[string]string{"include/libfoo/sub/foo.h": ""},
+			map[string]string{"include/libfoo/sub": "../../lib/linux_amd64/libfoo"}),
+		newtargz(t, map[string]string{h: ""}, map[string]string{
+			"include/libfoo/d": "../../lib/linux_amd64/libfoo",
+			"include/libfoo/e": "d/../../../..",
+		}),
+		// Lexically e points to include/libfoo/x, but d is the root.
+		newtargz(t, map[string]string{h: ""}, map[string]string{
+			"include/libfoo/d": "../..",
+			"include/libfoo/e": "d/../x",
+		}),
+	}
+	root := filepath.Join(dir, "root")
+	for i, p := range archives {
+		file := filepath.Join(dir, "libfoo.archive")
+		if err = ioutil.WriteFile(file, p, 0644); err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+		if err = install(root, file, "libfoo"); err == nil {
+			t.Errorf("expected err!=nil (i=%d)", i)
+		}
+		if _, err = os.Stat(filepath.Join(root, "include")); !os.IsNotExist(err) {
+			t.Errorf("expected nothing to be installed; was err=%v (i=%d)", err, i)
+		}
+		checkRoot(t, dir, root, file)
+	}
+}
+
+func FuzzInstall(f *testing.F) {
+	f.Add(newzipentries(f, "include/libfoo/foo.h", "lib/linux_amd64/libfoo/libfoo.pc"))
+	f.Add(newzipentries(f, "include/libfoo/../../evil.h"))
+	f.Add(newzipentries(f, "include/libfoo/foo.h", "include/libfoo/foo.h"))
+	f.Fuzz(func(t *testing.T, p []byte) {
+		dir, err := ioutil.TempDir("", "pkgconfig")
+		if err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+		defer os.RemoveAll(dir)
+		file := filepath.Join(dir, "libfoo.archive")
+		if err = ioutil.WriteFile(file, p, 0644); err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+		root := filepath.Join(dir, "root")
+		install(root, file, "libfoo")
+		checkRoot(t, dir, root, file)
+	})
+}
+
+func FuzzValidFile(f *testing.F) {
+	for _, name := range []string{"include/libfoo/foo.h", "lib/linux_amd64/libfoo/libfoo.pc", "include/libfoo/../x"} {
+		f.Add(name)
+	}
+	f.Fuzz(func(t *testing.T, name string) {
+		if !validFile(name, "libfoo") {
+			return
+		}
+		if !safeName(name) {
+			t.Errorf("expected name=%q to be safe", name)
+		}
+		root := filepath.FromSlash("/root")
+		if p := filepath.Join(root, filepath.FromSlash(name)); !strings.HasPrefix(p, root+string(filepath.Separator)) {
+			t.Errorf("expected %q to stay within %s", p, root)
+		}
+	})
+}
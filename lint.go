@@ -0,0 +1,259 @@
+package pkgconfig
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic is a single problem reported by Lint. A zero Line means
+// the problem concerns the file as a whole.
+type Diagnostic struct {
+	File string
+	Line int
+	Msg  string
+}
+
+// String gives a file:line: msg representation of the diagnostic.
+func (d Diagnostic) String() string {
+	s := d.File
+	if d.Line != 0 {
+		s += ":" + strconv.Itoa(d.Line)
+	}
+	if s == "" {
+		return d.Msg
+	}
+	return s + ": " + d.Msg
+}
+
+var builtinVars = map[string]struct{}{
+	"GOPATH": {},
+	"GOOS":   {},
+	"GOARCH": {},
+}
+
+var requiredKeywords = []string{"Name", "Description", "Version"}
+
+// refs gives names of all the variables referenced by the value.
+func refs(s string) (names []string) {
+	for {
+		i := strings.Index(s, "${")
+		if i == -1 {
+			return
+		}
+		j := strings.IndexByte(s[i:], '}')
+		if j == -1 {
+			return
+		}
+		names = append(names, s[i+2:i+j])
+		s = s[i+j+1:]
+	}
+}
+
+func isabs(s string) bool {
+	for _, prefix := range []string{"-I", "-L", "-Wl,-rpath,"} {
+		if strings.HasPrefix(s, prefix) {
+			s = s[len(prefix):]
+			break
+		}
+	}
+	if strings.HasPrefix(s, "/") || strings.HasPrefix(s, `\`) {
+		return true
+	}
+	return len(s) > 2 && isalnum(s[0]) && s[1] == ':' && (s[2] == '/' || s[2] == '\\')
+}
+
+// Lint checks the .pc file for common mistakes, which otherwise are found only
+// at build time:
+//
+//   - missing Name, Description or Version keywords
+//   - duplicated variables and keywords
+//   - variables which are referenced but not defined, or defined but unused
+//   - hard-coded absolute paths instead of ones relative to ${GOPATH}
+//   - -l flags in Cflags and -I flags in Libs
+//
+// The returned diagnostics are sorted by line number.
+func (f *PCFile) Lint() (diag []Diagnostic) {
+	var (
+		vars     = make(map[string]int)
+		keywords = make(map[string]int)
+		used     = make(map[string]struct{})
+	)
+	report := func(line int, format string, v ...interface{}) {
+		diag = append(diag, Diagnostic{Line: line, Msg: fmt.Sprintf(format, v...)})
+	}
+	for _, l := range f.Lines {
+		var seen map[string]int
+		switch l.Kind {
+		case LineVar:
+			seen = vars
+		case LineKeyword:
+			seen = keywords
+		default:
+			continue
+		}
+		key := l.Name
+		if l.Kind == LineKeyword {
+			key = strings.ToLower(key)
+		}
+		if n, ok := seen[key]; ok {
+			report(l.Num, "duplicate %s (first defined at line %d)", l.Name, n)
+		} else {
+			seen[key] = l.Num
+		}
+		for _, ref := range refs(l.Value) {
+			used[ref] = struct{}{}
+			if _, ok := vars[ref]; ok {
+				continue
+			}
+			if _, ok := builtinVars[ref]; !ok {
+				report(l.Num, "undefined variable ${%s}", ref)
+			}
+		}
+		for _, field := range strings.Fields(l.Value) {
+			if isabs(field) {
+				report(l.Num, "hard-coded absolute path %q, use ${GOPATH} instead", field)
+			}
+			if l.Kind != LineKeyword {
+				continue
+			}
+			switch key {
+			case "cflags", "cflags.private":
+				if strings.HasPrefix(field, "-l") || strings.HasPrefix(field, "-L") {
+					report(l.Num, "linker flag %q in %s", field, l.Name)
+				}
+			case "libs", "libs.private":
+				if strings.HasPrefix(field, "-I") {
+					report(l.Num, "compiler flag %q in %s", field, l.Name)
+				}
+			}
+		}
+		if l.Kind == LineKeyword {
+			if err := (&PC{}).set(l.Name, l.Value); err != nil {
+				report(l.Num, "%v", err)
+			}
+		}
+	}
+	for _, keyword := range requiredKeywords {
+		if _, ok := keywords[strings.ToLower(keyword)]; !ok {
+			report(0, "missing %s", keyword)
+		}
+	}
+	for name, n := range vars {
+		if _, ok := used[name]; !ok {
+			report(n, "unused variable %s", name)
+		}
+	}
+	sort.SliceStable(diag, func(i, j int) bool { return diag[i].Line < diag[j].Line })
+	return diag
+}
+
+// LintFile reads the given .pc file and lints it.
+func LintFile(file string) ([]Diagnostic, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	pcf, err := ParsePCFile(f)
+	if err != nil {
+		return nil, err
+	}
+	diag := pcf.Lint()
+	for i := range diag {
+		diag[i].File = file
+	}
+	return diag, nil
+}
+
+var keywordOrder = []string{
+	"Name",
+	"Description",
+	"Version",
+	"URL",
+	"Requires",
+	"Requires.private",
+	"Conflicts",
+	"Provides",
+	"Libs",
+	"Libs.private",
+	"Cflags",
+	"Cflags.private",
+}
+
+func keywordRank(name string) (int, string) {
+	for i, keyword := range keywordOrder {
+		if strings.EqualFold(name, keyword) {
+			return i, keyword
+		}
+	}
+	return len(keywordOrder), name
+}
+
+// Format canonicalizes the .pc file: variables go first, followed by a single
+// blank line and keywords in the conventional order, with unknown keywords
+// last. Values have their whitespace collapsed, keywords are spelled in their
+// canonical case. Comments stay attached to the line which follows them;
+// the comments which lead the file stay at its top.
+func (f *PCFile) Format() {
+	type group struct {
+		comments []Line
+		line     Line
+	}
+	var (
+		head     []Line
+		vars     []group
+		keywords []group
+		comments []Line
+		lead     = true
+	)
+	for _, l := range f.Lines {
+		switch l.Kind {
+		case LineBlank:
+			if lead && len(comments) != 0 {
+				head, comments = append(head, comments...), nil
+			}
+		case LineComment:
+			l.raw = ""
+			comments = append(comments, l)
+		case LineVar, LineKeyword:
+			lead = false
+			l.Value, l.raw = strings.Join(strings.Fields(l.Value), " "), ""
+			if l.Kind == LineVar {
+				vars = append(vars, group{comments, l})
+			} else {
+				_, l.Name = keywordRank(l.Name)
+				keywords = append(keywords, group{comments, l})
+			}
+			comments = nil
+		}
+	}
+	sort.SliceStable(keywords, func(i, j int) bool {
+		n, _ := keywordRank(keywords[i].line.Name)
+		m, _ := keywordRank(keywords[j].line.Name)
+		return n < m
+	})
+	lines := make([]Line, 0, len(f.Lines))
+	lines = append(lines, head...)
+	if len(head) != 0 && len(vars) != 0 {
+		lines = append(lines, Line{Kind: LineBlank})
+	}
+	for _, g := range vars {
+		lines = append(append(lines, g.comments...), g.line)
+	}
+	// Keywords are separated from the lines above them, if any.
+	if len(keywords) != 0 && len(lines) != 0 {
+		lines = append(lines, Line{Kind: LineBlank})
+	}
+	for _, g := range keywords {
+		lines = append(append(lines, g.comments...), g.line)
+	}
+	// Trailing comments are kept at the end of the file.
+	lines = append(lines, comments...)
+	for i := range lines {
+		lines[i].Num = i + 1
+	}
+	f.Lines = lines
+}
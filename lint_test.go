@@ -0,0 +1,157 @@
+package pkgconfig
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	raw := []byte(`prefix=/usr/local
+libdir=${prefix}/lib
+includedir=${GOPATH}/include/libfoo
+unused=x
+
+Name: libfoo
+Description: Foo library
+Libs: -L${libdir} -lfoo -I${includedir}
+Cflags: -I${includedir} -lm
+Name: libbar
+Requires: ${missing}
+`)
+	f, err := ParsePCFile(bytes.NewBuffer(raw))
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	exp := []Diagnostic{
+		{Line: 0, Msg: "missing Version"},
+		{Line: 1, Msg: `hard-coded absolute path "/usr/local", use ${GOPATH} instead`},
+		{Line: 4, Msg: "unused variable unused"},
+		{Line: 8, Msg: `compiler flag "-I${includedir}" in Libs`},
+		{Line: 9, Msg: `linker flag "-lm" in Cflags`},
+		{Line: 10, Msg: "duplicate Name (first defined at line 6)"},
+		{Line: 11, Msg: "undefined variable ${missing}"},
+	}
+	if diag := f.Lint(); !reflect.DeepEqual(diag, exp) {
+		t.Errorf("expected diag=%v; was %v", exp, diag)
+	}
+}
+
+func TestLintClean(t *testing.T) {
+	f, err := ParsePCFile(bytes.NewBuffer(libgit2file))
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if diag := f.Lint(); len(diag) != 0 {
+		t.Errorf("expected len(diag)=0; was %v", diag)
+	}
+}
+
+func TestDiagnosticString(t *testing.T) {
+	cases := [...]struct {
+		d   Diagnostic
+		exp string
+	}{
+		{Diagnostic{"a.pc", 3, "msg"}, "a.pc:3: msg"},
+		{Diagnostic{"a.pc", 0, "msg"}, "a.pc: msg"},
+		{Diagnostic{"", 0, "msg"}, "msg"},
+	}
+	for i, cas := range cases {
+		if s := cas.d.String(); s != cas.exp {
+			t.Errorf("expected s=%q; was %q (i=%d)", cas.exp, s, i)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	raw := []byte(`# libfoo
+
+Cflags:   -I${includedir}
+libs:  -L${libdir}    -lfoo
+# the version
+version: 1.0
+X-Custom: x
+prefix = ${GOPATH}
+
+# paths
+libdir=${prefix}/lib
+includedir=${prefix}/include
+Name: libfoo
+`)
+	exp := []byte(`# libfoo
+
+prefix=${GOPATH}
+# paths
+libdir=${prefix}/lib
+includedir=${prefix}/include
+
+Name: libfoo
+# the version
+Version: 1.0
+Libs: -L${libdir} -lfoo
+Cflags: -I${includedir}
+X-Custom: x
+`)
+	f, err := ParsePCFile(bytes.NewBuffer(raw))
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	f.Format()
+	var buf bytes.Buffer
+	if _, err = f.WriteTo(&buf); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if !bytes.Equal(buf.Bytes(), exp) {
+		t.Errorf("expected buf=%q; was %q", exp, buf.Bytes())
+	}
+	// Formatting is idempotent.
+	if f, err = ParsePCFile(bytes.NewBuffer(exp)); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	f.Format()
+	buf.Reset()
+	f.WriteTo(&buf)
+	if !bytes.Equal(buf.Bytes(), exp) {
+		t.Errorf("expected buf=%q; was %q", exp, buf.Bytes())
+	}
+}
+
+func TestFormatNewPC(t *testing.T) {
+	cases := [...]string{
+		"# libfoo\nName: libfoo\nVersion: 1.0\nCflags: -I/x\n",
+		"Cflags: -I/x\nName: libfoo\nDescription:\nVersion: 1.0\n",
+		"# libfoo\n\nName: libfoo\nprefix=/x\nVersion: 1.0\nRequires:\nLibs: -L${prefix}/lib -lfoo\n",
+	}
+	for i, cas := range cases {
+		exp, err := NewPC(strings.NewReader(cas))
+		if err != nil {
+			t.Errorf("expected err=nil; was %q (i=%d)", err, i)
+			continue
+		}
+		f, err := ParsePCFile(strings.NewReader(cas))
+		if err != nil {
+			t.Errorf("expected err=nil; was %q (i=%d)", err, i)
+			continue
+		}
+		f.Format()
+		var buf bytes.Buffer
+		if _, err = f.WriteTo(&buf); err != nil {
+			t.Errorf("expected err=nil; was %q (i=%d)", err, i)
+			continue
+		}
+		for _, line := range strings.Split(buf.String(), "\n") {
+			if strings.HasSuffix(line, " ") {
+				t.Errorf("expected no trailing space; was %q (i=%d)", line, i)
+			}
+		}
+		pc, err := NewPC(&buf)
+		if err != nil {
+			t.Errorf("expected err=nil; was %q (i=%d)", err, i)
+			continue
+		}
+		if !reflect.DeepEqual(pc, exp) {
+			t.Errorf("expected pc=%+v; was %+v (i=%d)", exp, pc, i)
+		}
+	}
+}
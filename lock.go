@@ -0,0 +1,101 @@
+package pkgconfig
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LockFileName is the name of a lock file, which lists the libraries fetched
+// from project releases with all the information needed to install exactly
+// the same archives again. Each line has the following format:
+//
+//	LIBRARY PROJECT TAG URL sha256:HEX
+const LockFileName = "cdeps.lock"
+
+// LockEntry describes a single library fetched from a project release.
+type LockEntry struct {
+	Lib  string // library name as requested, e.g. libfoo or libfoo@v1.2.0
+	Proj string // source project, e.g. github.com/user/proj
+	Tag  string // release tag
+	URL  string // archive URL
+	Sum  string // archive checksum
+}
+
+// LockFile is a parsed lock file.
+type LockFile struct {
+	File    string
+	Entries []LockEntry
+}
+
+// ReadLockFile reads the given lock file. A lock file which does not exist
+// is treated as an empty one.
+func ReadLockFile(file string) (*LockFile, error) {
+	l := &LockFile{File: file}
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	buf := bufio.NewScanner(f)
+	for n := 1; buf.Scan(); n++ {
+		v := strings.Fields(buf.Text())
+		if len(v) == 0 || strings.HasPrefix(v[0], "#") {
+			continue
+		}
+		if len(v) != 5 || !validSum(v[4]) {
+			return nil, fmt.Errorf("%s:%d: malformed line", file, n)
+		}
+		l.Entries = append(l.Entries, LockEntry{v[0], v[1], v[2], v[3], v[4]})
+	}
+	if err = buf.Err(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Set adds the entry to the lock file, replacing the one for the same library.
+func (l *LockFile) Set(e LockEntry) {
+	for i := range l.Entries {
+		if l.Entries[i].Lib == e.Lib {
+			l.Entries[i] = e
+			return
+		}
+	}
+	l.Entries = append(l.Entries, e)
+}
+
+// Write saves the lock file, with its entries sorted by library name.
+func (l *LockFile) Write() error {
+	sort.Slice(l.Entries, func(i, j int) bool { return l.Entries[i].Lib < l.Entries[j].Lib })
+	var buf bytes.Buffer
+	for _, e := range l.Entries {
+		fmt.Fprintf(&buf, "%s %s %s %s %s\n", e.Lib, e.Proj, e.Tag, e.URL, e.Sum)
+	}
+	return ioutil.WriteFile(l.File, buf.Bytes(), 0644)
+}
+
+// DefaultLockFile gives a path of the lock file in the root of the current
+// module, which is the nearest directory with a go.mod file. If there's none,
+// the root of the current project is used instead.
+func DefaultLockFile() string {
+	for d := wd; d != ""; {
+		if existFile(filepath.Join(d, "go.mod")) == nil {
+			return filepath.Join(d, LockFileName)
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+	return filepath.Join(projroot(wd, githubProj), LockFileName)
+}
@@ -0,0 +1,98 @@
+package pkgconfig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLockFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, LockFileName)
+	l, err := ReadLockFile(file)
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if len(l.Entries) != 0 {
+		t.Errorf("expected len(l.Entries)=0; was %d", len(l.Entries))
+	}
+	a := LockEntry{"libz", "github.com/a/b", "pkg-config", "https://a/libz.zip", "sha256:" + strings.Repeat("00", 32)}
+	b := LockEntry{"liba@v1", "github.com/a/b", "v1", "https://a/liba.zip", "sha256:" + strings.Repeat("01", 32)}
+	c := LockEntry{"libz", "github.com/a/c", "pkg-config", "https://c/libz.zip", "sha256:" + strings.Repeat("02", 32)}
+	l.Set(a)
+	l.Set(b)
+	l.Set(c)
+	if err = l.Write(); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if l, err = ReadLockFile(file); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if exp := []LockEntry{b, c}; !reflect.DeepEqual(l.Entries, exp) {
+		t.Errorf("expected l.Entries=%+v; was %+v", exp, l.Entries)
+	}
+	for i, cas := range []string{"libz github.com/a/b pkg-config https://a/libz.zip\n",
+		"libz github.com/a/b pkg-config https://a/libz.zip md5:00\n",
+		"libz github.com/a/b pkg-config https://a/libz.zip sha256:00\n",
+		"libz github.com/a/b pkg-config https://a/libz.zip sha256:../../../x\n"} {
+		if err = ioutil.WriteFile(file, []byte(cas), 0644); err != nil {
+			t.Fatalf("expected err=nil; was %q (i=%d)", err, i)
+		}
+		if _, err = ReadLockFile(file); err == nil {
+			t.Errorf("expected err!=nil (i=%d)", i)
+		}
+	}
+}
+
+func TestFetcherSync(t *testing.T) {
+	dir, restore := tempgopath(t)
+	defer restore()
+	p := libfoozip(t)
+	srv := newLibfooServer(t, p, "")
+	defer srv.Close()
+	f := &Fetcher{Client: srv.Client(), BaseURL: srv.URL + "/"}
+	e, err := f.Get("libfoo", "github.com/user/proj")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	h := sha256.Sum256(p)
+	exp := &LockEntry{
+		Lib:  "libfoo",
+		Proj: "github.com/user/proj",
+		Tag:  DefaultTag,
+		URL:  srv.URL + libfooPath,
+		Sum:  "sha256:" + hex.EncodeToString(h[:]),
+	}
+	if !reflect.DeepEqual(e, exp) {
+		t.Errorf("expected e=%+v; was %+v", exp, e)
+	}
+	os.RemoveAll(filepath.Join(dir, "include"))
+	l := &LockFile{Entries: []LockEntry{*e}}
+	if err = f.Sync(l); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if err = existFile(filepath.Join(dir, "include", "libfoo", "foo.h")); err != nil {
+		t.Errorf("expected err=nil; was %q", err)
+	}
+	os.RemoveAll(filepath.Join(dir, "include"))
+	l.Entries[0].Sum = "sha256:" + hex.EncodeToString(make([]byte, 32))
+	if _, ok := f.Sync(l).(*ChecksumError); !ok {
+		t.Errorf("expected drift to be reported")
+	}
+	if existDir(filepath.Join(dir, "include")) == nil {
+		t.Errorf("expected nothing to be installed")
+	}
+	l.Entries[0].Sum = ""
+	if err = f.Sync(l); err == nil {
+		t.Errorf("expected err!=nil")
+	}
+}
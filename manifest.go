@@ -0,0 +1,219 @@
+package pkgconfig
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// manifestExt is the extension of an install manifest, which is written for
+// each installed library into the $GOPATH/pkg/pkg-config directory. The first
+// line of the manifest is the lock file entry of the library, the following
+// ones are the installed files:
+//
+//	LIBRARY PROJECT TAG URL sha256:HEX
+//	include/LIBRARY/FILE
+//	lib/TARGET/LIBRARY/FILE
+const manifestExt = ".manifest"
+
+// Manifest describes a library installed into a $GOPATH workspace.
+type Manifest struct {
+	LockEntry
+	// Root is the directory the library is installed into, either the
+	// workspace itself or the TagRoot of a LIB@TAG package.
+	Root string
+	// Files are the installed files, slash-separated and relative to the Root.
+	Files []string
+}
+
+func manifestFile(gopath, pkg string) string {
+	return filepath.Join(gopath, "pkg", "pkg-config", pkg+manifestExt)
+}
+
+func installRoot(gopath, pkg string) string {
+	if name, _ := splittag(pkg); name != pkg {
+		return TagRoot(gopath, pkg)
+	}
+	return gopath
+}
+
+// writeManifest records the files of the archive installed into the workspace.
+// The files of the previous installation, which were left in the directories
+// the archive did not replace, are kept in the manifest.
+func writeManifest(gopath string, l *LockEntry, file string) error {
+	var (
+		buf   bytes.Buffer
+		files = make(map[string]struct{})
+	)
+	fmt.Fprintf(&buf, "%s %s %s %s %s\n", l.Lib, l.Proj, l.Tag, l.URL, l.Sum)
+	err := walkArchive(file, func(e *entry) error {
+		if !e.mode.IsDir() {
+			files[e.name] = struct{}{}
+			fmt.Fprintln(&buf, e.name)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// A missing or malformed previous manifest has nothing to keep.
+	if m, err := ReadManifest(gopath, l.Lib); err == nil {
+		for _, name := range m.Files {
+			if _, ok := files[name]; ok {
+				continue
+			}
+			if _, err := os.Lstat(filepath.Join(m.Root, filepath.FromSlash(name))); err == nil {
+				fmt.Fprintln(&buf, name)
+			}
+		}
+	}
+	return writeFile(manifestFile(gopath, l.Lib), &buf)
+}
+
+// ReadManifest reads the install manifest of the given package from
+// the workspace.
+func ReadManifest(gopath, pkg string) (*Manifest, error) {
+	file := manifestFile(gopath, pkg)
+	p, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{Root: installRoot(gopath, pkg)}
+	buf := bufio.NewScanner(bytes.NewReader(p))
+	for n := 1; buf.Scan(); n++ {
+		if n == 1 {
+			v := strings.Fields(buf.Text())
+			if len(v) != 5 || v[0] != pkg {
+				return nil, fmt.Errorf("%s:%d: malformed line", file, n)
+			}
+			m.LockEntry = LockEntry{v[0], v[1], v[2], v[3], v[4]}
+			continue
+		}
+		if name := strings.TrimSpace(buf.Text()); name != "" {
+			if !validFile(name, m.name()) {
+				return nil, fmt.Errorf("%s:%d: unexpected file %q", file, n, name)
+			}
+			m.Files = append(m.Files, name)
+		}
+	}
+	if m.Lib == "" {
+		return nil, fmt.Errorf("%s: empty manifest", file)
+	}
+	return m, buf.Err()
+}
+
+func (m *Manifest) name() string {
+	name, _ := splittag(m.Lib)
+	return name
+}
+
+// Targets gives the GOOS_GOARCH targets the library was installed for.
+func (m *Manifest) Targets() []string {
+	var targets []string
+	seen := make(map[string]struct{})
+	for _, name := range m.Files {
+		if v := strings.Split(name, "/"); len(v) > 2 && v[0] == "lib" {
+			if _, ok := seen[v[1]]; !ok {
+				seen[v[1]] = struct{}{}
+				targets = append(targets, v[1])
+			}
+		}
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// Version gives the version of the installed library, read from its .pc file
+// for the current target, or for any other target if there's none. It gives
+// empty string if the version is unknown.
+func (m *Manifest) Version() string {
+	name := m.name()
+	files := []string{"lib/" + runtime.GOOS + "_" + runtime.GOARCH + "/" + name + "/" + name + ".pc"}
+	for _, target := range m.Targets() {
+		files = append(files, "lib/"+target+"/"+name+"/"+name+".pc")
+	}
+	for _, file := range files {
+		f, err := os.Open(filepath.Join(m.Root, filepath.FromSlash(file)))
+		if err != nil {
+			continue
+		}
+		pcf, err := ParsePCFile(f)
+		f.Close()
+		if err != nil {
+			continue
+		}
+		if pc, err := pcf.PC(map[string]string{"GOPATH": m.Root}); err == nil && pc.Version != "" {
+			return pc.Version
+		}
+	}
+	return ""
+}
+
+// ListInstalled gives the manifests of all the libraries installed into
+// the workspace, sorted by library name.
+func ListInstalled(gopath string) ([]*Manifest, error) {
+	files, err := filepath.Glob(filepath.Join(gopath, "pkg", "pkg-config", "*"+manifestExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	manifests := make([]*Manifest, 0, len(files))
+	for _, file := range files {
+		m, err := ReadManifest(gopath, strings.TrimSuffix(filepath.Base(file), manifestExt))
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, m)
+	}
+	return manifests, nil
+}
+
+// Uninstall removes the files of the library recorded in its install manifest
+// and then the directories left empty, and finally the manifest itself.
+func Uninstall(gopath, pkg string) error {
+	unlock, err := lockLib(gopath, pkg)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	m, err := ReadManifest(gopath, pkg)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s is not installed in %s", pkg, gopath)
+	}
+	if err != nil {
+		return err
+	}
+	dirs := make(map[string]struct{})
+	for _, name := range m.Files {
+		if err = os.Remove(filepath.Join(m.Root, filepath.FromSlash(name))); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for dir := path.Dir(name); dir != "." && dir != "lib" && dir != "include"; dir = path.Dir(dir) {
+			dirs[dir] = struct{}{}
+		}
+	}
+	// Deeper directories go first, so their parents are empty once they're
+	// removed.
+	sorted := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	for _, dir := range sorted {
+		// Removing a non-empty directory fails, which is fine.
+		os.Remove(filepath.Join(m.Root, filepath.FromSlash(dir)))
+	}
+	if m.Root != gopath {
+		os.Remove(filepath.Join(m.Root, "include"))
+		os.Remove(filepath.Join(m.Root, "lib"))
+		os.Remove(m.Root)
+	}
+	return os.Remove(manifestFile(gopath, pkg))
+}
@@ -0,0 +1,153 @@
+package pkgconfig
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestInstallManifest(t *testing.T) {
+	dir, restore := tempgopath(t)
+	defer restore()
+	archives := map[string][]byte{
+		"/github.com/user/foo/releases/download/pkg-config/libfoo.zip": libzip(t, "libfoo", "", ""),
+		"/github.com/user/foo/releases/download/v2.0/libfoo.zip":       libzip(t, "libfoo", "", ""),
+		"/github.com/user/bar/releases/download/pkg-config/libbar.zip": libzip(t, "libbar", "", ""),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := archives[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(p)
+	}))
+	defer srv.Close()
+	f := &Fetcher{Client: srv.Client(), BaseURL: srv.URL + "/"}
+	for _, lib := range []string{"libfoo", "libfoo@v2.0"} {
+		if _, err := f.Get(lib, "github.com/user/foo"); err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+	}
+	if _, err := f.Get("libbar", "github.com/user/bar"); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	manifests, err := ListInstalled(dir)
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	var libs []string
+	for _, m := range manifests {
+		libs = append(libs, m.Lib)
+	}
+	if exp := []string{"libbar", "libfoo", "libfoo@v2.0"}; !reflect.DeepEqual(libs, exp) {
+		t.Fatalf("expected libs=%v; was %v", exp, libs)
+	}
+	for i, m := range manifests {
+		if exp := []string{target}; !reflect.DeepEqual(m.Targets(), exp) {
+			t.Errorf("expected targets=%v; was %v (i=%d)", exp, m.Targets(), i)
+		}
+		if v := m.Version(); v != "1.0" {
+			t.Errorf("expected version=1.0; was %q (i=%d)", v, i)
+		}
+		if !strings.HasPrefix(m.URL, srv.URL) || m.Sum == "" {
+			t.Errorf("expected origin to be recorded; was %+v (i=%d)", m.LockEntry, i)
+		}
+	}
+	if exp := TagRoot(dir, "libfoo@v2.0"); manifests[2].Root != exp {
+		t.Errorf("expected root=%q; was %q", exp, manifests[2].Root)
+	}
+	foo := manifests[1].Files
+	for _, lib := range []string{"libfoo", "libfoo@v2.0"} {
+		if err = Uninstall(dir, lib); err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+	}
+	for i, name := range foo {
+		if _, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(name))); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed; was err=%v (i=%d)", name, err, i)
+		}
+	}
+	for i, d := range []string{
+		filepath.Join(dir, "include", "libfoo"),
+		filepath.Join(dir, "lib", target, "libfoo"),
+		TagRoot(dir, "libfoo@v2.0"),
+	} {
+		if _, err := os.Stat(d); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be pruned; was err=%v (i=%d)", d, err, i)
+		}
+	}
+	if _, err = LookupGopath("libbar"); err != nil {
+		t.Errorf("expected err=nil; was %q", err)
+	}
+	if manifests, err = ListInstalled(dir); err != nil || len(manifests) != 1 {
+		t.Errorf("expected libbar to be left; was %v (err=%v)", manifests, err)
+	}
+	if err = Uninstall(dir, "libfoo"); err == nil {
+		t.Error("expected err!=nil")
+	}
+}
+
+func TestReinstallManifest(t *testing.T) {
+	dir, restore := tempgopath(t)
+	defer restore()
+	lib := "lib/" + target + "/libfoo/"
+	other := "lib/plan9_386/libfoo/"
+	archives := []map[string]string{
+		{
+			"include/libfoo/foo.h":  "v1",
+			"include/libfoo/old.h":  "v1",
+			lib + "libfoo.pc":       "Name: libfoo\nVersion: 1.0\n",
+			other + "libfoo.pc":     "Name: libfoo\nVersion: 1.0\n",
+			other + "libfoo.so.1.0": "v1",
+		},
+		{
+			"include/libfoo/foo.h": "v2",
+			lib + "libfoo.pc":      "Name: libfoo\nVersion: 2.0\n",
+		},
+	}
+	var p []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != libfooPath {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(p)
+	}))
+	defer srv.Close()
+	f := &Fetcher{Client: srv.Client(), BaseURL: srv.URL + "/"}
+	for i, files := range archives {
+		p = newzip(t, files)
+		if _, err := f.Get("libfoo", "github.com/user/proj"); err != nil {
+			t.Fatalf("expected err=nil; was %q (i=%d)", err, i)
+		}
+	}
+	m, err := ReadManifest(dir, "libfoo")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	sort.Strings(m.Files)
+	// The other target was not replaced, the old header was.
+	exp := []string{"include/libfoo/foo.h", lib + "libfoo.pc", other + "libfoo.pc", other + "libfoo.so.1.0"}
+	sort.Strings(exp)
+	if !reflect.DeepEqual(m.Files, exp) {
+		t.Errorf("expected files=%v; was %v", exp, m.Files)
+	}
+	if err = Uninstall(dir, "libfoo"); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	for i, d := range []string{
+		filepath.Join(dir, "include", "libfoo"),
+		filepath.Join(dir, "lib", target, "libfoo"),
+		filepath.Join(dir, "lib", "plan9_386", "libfoo"),
+	} {
+		if _, err := os.Stat(d); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed; was err=%v (i=%d)", d, err, i)
+		}
+	}
+}
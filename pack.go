@@ -0,0 +1,234 @@
+package pkgconfig
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// packTime is the modification time of all the files within an archive
+// written by Pack, which makes the archive reproducible.
+var packTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// packFile is a single file of the library tree to be packed.
+type packFile struct {
+	name string // slash-separated name within the archive
+	file string // path of the file on disk
+	mode os.FileMode
+}
+
+// packFiles collects the files of the library from the include/LIB directory
+// and the lib/TARGET/LIB directories of the given platforms. If no platforms
+// are given, all the targets the library was built for are packed.
+func packFiles(gopath, pkg string, platforms []string) ([]packFile, error) {
+	if len(platforms) == 0 {
+		fis, err := ioutil.ReadDir(filepath.Join(gopath, "lib"))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, fi := range fis {
+			if _, ok := targets[fi.Name()]; ok && existDir(filepath.Join(gopath, "lib", fi.Name(), pkg)) == nil {
+				platforms = append(platforms, fi.Name())
+			}
+		}
+		if len(platforms) == 0 {
+			return nil, fmt.Errorf("no library files found for %s", pkg)
+		}
+	}
+	dirs := []string{"include/" + pkg}
+	for _, target := range platforms {
+		if _, ok := targets[target]; !ok {
+			return nil, fmt.Errorf("unsupported target %q", target)
+		}
+		dirs = append(dirs, "lib/"+target+"/"+pkg)
+	}
+	var files []packFile
+	for _, dir := range dirs {
+		root := filepath.Join(gopath, filepath.FromSlash(dir))
+		if existDir(root) != nil {
+			if dir == dirs[0] {
+				// A library may have no headers.
+				continue
+			}
+			return nil, fmt.Errorf("no %s directory in %s", dir, gopath)
+		}
+		err := filepath.Walk(root, func(file string, fi os.FileInfo, err error) error {
+			if err != nil || fi.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(gopath, file)
+			if err != nil {
+				return err
+			}
+			f := packFile{name: filepath.ToSlash(rel), file: file, mode: fi.Mode()}
+			if !f.mode.IsRegular() && f.mode&os.ModeSymlink == 0 {
+				return fmt.Errorf("unsupported file %s", file)
+			}
+			files = append(files, f)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+	return files, nil
+}
+
+// rewritePC replaces the absolute path of the $GOPATH workspace within
+// the variables and keywords of the .pc file with ${GOPATH}.
+func rewritePC(p []byte, gopath string) ([]byte, error) {
+	f, err := ParsePCFile(bytes.NewReader(p))
+	if err != nil {
+		return nil, err
+	}
+	gopath = filepath.Clean(gopath)
+	for i, l := range f.Lines {
+		if l.Kind != LineVar && l.Kind != LineKeyword {
+			continue
+		}
+		v := replaceGopath(replaceGopath(l.Value, gopath), filepath.ToSlash(gopath))
+		if v != l.Value {
+			f.Lines[i].Value, f.Lines[i].raw = v, ""
+		}
+	}
+	var buf bytes.Buffer
+	if _, err = f.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// replaceGopath replaces the workspace path within s with ${GOPATH}, where
+// it's followed by a path separator or ends the token, so a longer path
+// which merely starts with it, like $GOPATH2, is left intact.
+func replaceGopath(s, gopath string) string {
+	var buf bytes.Buffer
+	for {
+		i := strings.Index(s, gopath)
+		if i == -1 {
+			break
+		}
+		j := i + len(gopath)
+		if j == len(s) || strings.IndexByte("/ \t"+string(filepath.Separator), s[j]) != -1 {
+			buf.WriteString(s[:i])
+			buf.WriteString("${GOPATH}")
+		} else {
+			buf.WriteString(s[:j])
+		}
+		s = s[j:]
+	}
+	buf.WriteString(s)
+	return buf.String()
+}
+
+// Pack writes a zip archive of the library installed in the given $GOPATH
+// workspace, ready to be attached to a project release. The archive holds
+// the include/LIB directory and the lib/TARGET/LIB directories of the given
+// platforms, or of all the targets the library was built for if none are given.
+// The archive is checked with the same rules it is installed with,
+// and absolute paths of the workspace within .pc files are replaced with
+// ${GOPATH}. The archive is reproducible: the files are sorted and have
+// fixed modification times.
+func Pack(w io.Writer, gopath, pkg string, platforms []string) error {
+	files, err := packFiles(gopath, pkg, platforms)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		if !validFile(f.name, pkg) {
+			return fmt.Errorf("unexcpected file %q", f.name)
+		}
+		var p []byte
+		mode := os.FileMode(0644)
+		switch {
+		case f.mode&os.ModeSymlink != 0:
+			link, err := os.Readlink(f.file)
+			if err != nil {
+				return err
+			}
+			link = filepath.ToSlash(link)
+			if !validLink(f.name, link) {
+				return fmt.Errorf("symlink %q points outside of the install root: %q", f.name, link)
+			}
+			p, mode = []byte(link), os.ModeSymlink|0777
+		default:
+			if p, err = ioutil.ReadFile(f.file); err != nil {
+				return err
+			}
+			if f.mode.Perm()&0111 != 0 {
+				mode = 0755
+			}
+			if path.Ext(f.name) == ".pc" {
+				if p, err = rewritePC(p, gopath); err != nil {
+					return fmt.Errorf("%s: %v", f.file, err)
+				}
+			}
+		}
+		hdr := &zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: packTime}
+		hdr.SetMode(mode)
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		if _, err = fw.Write(p); err != nil {
+			return err
+		}
+	}
+	if err = zw.Close(); err != nil {
+		return err
+	}
+	if err = validPack(buf.Bytes(), pkg); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// validPack checks the packed archive with all the rules it is going to be
+// installed with, so no archive is released which then fails to install.
+func validPack(p []byte, pkg string) error {
+	f, err := ioutil.TempFile("", "pkg-config-pack-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(p)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	_, err = validArchive(f.Name(), pkg)
+	return err
+}
+
+// PackFile writes the archive of the library with Pack to the given file,
+// together with the file.sha256 checksum in the sha256sum format, which
+// should be attached to the release alongside the archive.
+func PackFile(file, gopath, pkg string, platforms []string) error {
+	var buf bytes.Buffer
+	if err := Pack(&buf, gopath, pkg, platforms); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(file, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	sum, err := sha256file(file)
+	if err != nil {
+		return err
+	}
+	s := strings.TrimPrefix(sum, "sha256:") + "  " + filepath.Base(file) + "\n"
+	return ioutil.WriteFile(file+".sha256", []byte(s), 0644)
+}
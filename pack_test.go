@@ -0,0 +1,185 @@
+package pkgconfig
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func packTree(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+		content = strings.Replace(content, "$DIR", filepath.ToSlash(dir), -1)
+		if err = ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+	}
+	return dir
+}
+
+func TestPack(t *testing.T) {
+	dir := packTree(t, map[string]string{
+		"include/libfoo/foo.h": "#define FOO 1\n",
+		"lib/linux_amd64/libfoo/libfoo.pc": "libdir=$DIR/lib/linux_amd64/libfoo\n\n" +
+			"Name: libfoo\nVersion: 1.0\nLibs: -L${libdir} -lfoo\nCflags: -I$DIR/include/libfoo\n",
+		"lib/linux_amd64/libfoo/libfoo.so":   "ELF",
+		"lib/windows_amd64/libfoo/libfoo.pc": "\nName: libfoo\nVersion: 1.0\n",
+		"lib/linux_amd64/libbar/libbar.pc":   "\nName: libbar\n",
+	})
+	defer os.RemoveAll(dir)
+	cases := [...]struct {
+		platforms []string
+		exp       []string
+	}{{
+		nil,
+		[]string{
+			"include/libfoo/foo.h",
+			"lib/linux_amd64/libfoo/libfoo.pc",
+			"lib/linux_amd64/libfoo/libfoo.so",
+			"lib/windows_amd64/libfoo/libfoo.pc",
+		},
+	}, {
+		[]string{"windows_amd64"},
+		[]string{
+			"include/libfoo/foo.h",
+			"lib/windows_amd64/libfoo/libfoo.pc",
+		},
+	}}
+	for i, cas := range cases {
+		file := filepath.Join(dir, "libfoo.zip")
+		if err := PackFile(file, dir, "libfoo", cas.platforms); err != nil {
+			t.Fatalf("expected err=nil; was %q (i=%d)", err, i)
+		}
+		names, err := walknames(file)
+		if err != nil {
+			t.Fatalf("expected err=nil; was %q (i=%d)", err, i)
+		}
+		if strings.Join(names, " ") != strings.Join(cas.exp, " ") {
+			t.Errorf("expected names=%v; was %v (i=%d)", cas.exp, names, i)
+		}
+		sum, err := sha256file(file)
+		if err != nil {
+			t.Fatalf("expected err=nil; was %q (i=%d)", err, i)
+		}
+		p, err := ioutil.ReadFile(file + ".sha256")
+		if exp := strings.TrimPrefix(sum, "sha256:") + "  libfoo.zip\n"; err != nil || string(p) != exp {
+			t.Errorf("expected checksum=%q; was %q (err=%v, i=%d)", exp, p, err, i)
+		}
+	}
+	// The archive is reproducible.
+	var a, b bytes.Buffer
+	if err := Pack(&a, dir, "libfoo", nil); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if err := os.Chtimes(filepath.Join(dir, "include", "libfoo", "foo.h"), packTime, packTime); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if err := Pack(&b, dir, "libfoo", nil); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if !bytes.Equal(a.Bytes(), b.Bytes()) {
+		t.Error("expected archives to be equal")
+	}
+	// Absolute paths within the .pc file are rewritten.
+	if runtime.GOOS+"_"+runtime.GOARCH != "linux_amd64" {
+		return
+	}
+	_, restore := tempgopath(t)
+	defer restore()
+	file := filepath.Join(dir, "libfoo.zip")
+	if err := ioutil.WriteFile(file, a.Bytes(), 0644); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	gopath := os.Getenv("GOPATH")
+	if err := install(gopath, file, "libfoo"); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	pc, err := LookupGopath("libfoo")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if exp := "-I" + filepath.Join(gopath, "include", "libfoo"); len(pc.Cflags) != 1 || pc.Cflags[0] != exp {
+		t.Errorf("expected pc.Cflags=[%s]; was %v", exp, pc.Cflags)
+	}
+}
+
+func TestRewritePC(t *testing.T) {
+	cases := [...]struct {
+		gopath string
+		value  string
+		exp    string
+	}{
+		{"/home/u/go", "-I/home/u/go/include/libfoo", "-I${GOPATH}/include/libfoo"},
+		{"/home/u/go/", "-L/home/u/go/lib -I/home/u/go/include", "-L${GOPATH}/lib -I${GOPATH}/include"},
+		{"/home/u/go", "/home/u/go", "${GOPATH}"},
+		{"/home/u/go", "-I/home/u/go2/include -I/home/u/go", "-I/home/u/go2/include -I${GOPATH}"},
+		{"/home/u/go/", "-I/home/u/gopher/include", "-I/home/u/gopher/include"},
+	}
+	for i, cas := range cases {
+		p, err := rewritePC([]byte("Cflags: "+cas.value+"\n"), cas.gopath)
+		if err != nil {
+			t.Errorf("expected err=nil; was %q (i=%d)", err, i)
+			continue
+		}
+		if exp := "Cflags: " + cas.exp + "\n"; string(p) != exp {
+			t.Errorf("expected p=%q; was %q (i=%d)", exp, p, i)
+		}
+	}
+}
+
+func TestPackErr(t *testing.T) {
+	dir := packTree(t, map[string]string{
+		"include/libfoo/foo.h":             "",
+		"lib/linux_amd64/libfoo/libfoo.pc": "\nName: libfoo\n",
+	})
+	defer os.RemoveAll(dir)
+	cases := [...]struct {
+		pkg       string
+		platforms []string
+	}{
+		{"libbar", nil},
+		{"libfoo", []string{"linux_amd128"}},
+		{"libfoo", []string{"darwin_amd64"}},
+	}
+	for i, cas := range cases {
+		if err := Pack(ioutil.Discard, dir, cas.pkg, cas.platforms); err == nil {
+			t.Errorf("expected err!=nil (i=%d)", i)
+		}
+	}
+	// Archives, which would not install, are not packed either.
+	old := limits
+	limits.files = 1
+	err := Pack(ioutil.Discard, dir, "libfoo", nil)
+	limits = old
+	if err == nil {
+		t.Error("expected err!=nil")
+	}
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		return
+	}
+	foo := filepath.Join(dir, "include", "libfoo", "FOO.h")
+	if err := ioutil.WriteFile(foo, nil, 0644); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if err := Pack(ioutil.Discard, dir, "libfoo", nil); err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Errorf("expected duplicate file error; was %v", err)
+	}
+	os.Remove(foo)
+	if err := os.Symlink("/etc/passwd", filepath.Join(dir, "lib", "linux_amd64", "libfoo", "passwd")); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if err := Pack(ioutil.Discard, dir, "libfoo", nil); err == nil {
+		t.Error("expected err!=nil")
+	}
+}
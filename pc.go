@@ -3,6 +3,7 @@ package pkgconfig
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -13,14 +14,19 @@ import (
 
 // PC TODO(rjeczalik): document
 type PC struct {
-	Name        string
-	Desc        string
-	Version     string
-	URL         string
-	Libs        []string
-	LibsPrivate []string
-	Cflags      []string
-	File        string
+	Name            string
+	Desc            string
+	Version         string
+	URL             string
+	Requires        []Dep
+	RequiresPrivate []Dep
+	Conflicts       []Dep
+	Provides        []Dep
+	Libs            []string
+	LibsPrivate     []string
+	Cflags          []string
+	CflagsPrivate   []string
+	File            string
 }
 
 // ErrEmptyPC TODO(rjeczalik): document
@@ -28,9 +34,6 @@ var ErrEmptyPC = errors.New("the package configuration is empty")
 
 // WriteTo TODO(rjeczalik): document
 func (pc *PC) WriteTo(w io.Writer) (int64, error) {
-	if len(pc.Libs) == 0 || len(pc.Cflags) == 0 {
-		return 0, ErrEmptyPC
-	}
 	var buf bytes.Buffer
 	buf.WriteByte('\n')
 	// TODO(rjeczalik): map interation order?
@@ -39,8 +42,13 @@ func (pc *PC) WriteTo(w io.Writer) (int64, error) {
 		{"Description", pc.Desc},
 		{"Version", pc.Version},
 		{"URL", pc.URL},
+		{"Requires", joinDeps(pc.Requires)},
+		{"Requires.private", joinDeps(pc.RequiresPrivate)},
+		{"Conflicts", joinDeps(pc.Conflicts)},
+		{"Provides", joinDeps(pc.Provides)},
 		{"Libs.private", strings.TrimSpace(strings.Join(pc.LibsPrivate, " "))},
 		{"Libs", strings.TrimSpace(strings.Join(pc.Libs, " "))},
+		{"Cflags.private", strings.TrimSpace(strings.Join(pc.CflagsPrivate, " "))},
 		{"Cflags", strings.TrimSpace(strings.Join(pc.Cflags, " "))},
 	} {
 		if item.v != "" {
@@ -91,16 +99,6 @@ func expand(p []byte, vars map[string][]byte) []byte {
 	return p
 }
 
-type state struct {
-	n string
-	c byte
-}
-
-var (
-	parseVar = &state{"variable", '='}
-	parseKey = &state{"keyword", ':'}
-)
-
 // NewPC TODO(rjeczalik): document
 func NewPC(r io.Reader) (*PC, error) {
 	return NewPCVars(r, make(map[string]string))
@@ -112,20 +110,16 @@ func NewPCVars(r io.Reader, vars map[string]string) (pc *PC, err error) {
 	var (
 		buf = bufio.NewReader(r)
 		m   = make(map[string][]byte, len(vars))
-		st  = parseVar
 		p   []byte
 		c   int
 	)
-	fail := func() error {
-		return fmt.Errorf("malformed %s line: %q", st.n, p)
-	}
 	for n, v := range vars {
 		m[n] = []byte(v)
 	}
-	for {
+	for num := 1; ; num++ {
 		p, err = buf.ReadBytes('\n')
 		p = bytes.TrimSpace(p)
-		if len(p) == 0 {
+		if len(p) == 0 || p[0] == '#' {
 			if err == io.EOF {
 				err = nil
 				if c == 0 {
@@ -136,50 +130,57 @@ func NewPCVars(r io.Reader, vars map[string]string) (pc *PC, err error) {
 			if err != nil {
 				return
 			}
-			st = parseKey
 			continue
 		}
 		c += len(p)
-		n := bytes.IndexByte(p, st.c)
-		if n == -1 {
-			err = fail()
+		// Lines are classified the same way ParsePCFile does.
+		var l Line
+		if l, err = parseLine(string(p), num); err != nil {
 			return
 		}
-		name := string(bytes.TrimSpace(p[:n]))
-		if len(name) == 0 {
-			err = fail()
+		value := []byte(l.Value)
+		if l.Kind == LineVar {
+			m[l.Name] = expand(value, m)
+		} else if err = pc.set(l.Name, string(expand(value, m))); err != nil {
 			return
 		}
-		value := bytes.TrimSpace(p[n+1:])
-		switch st {
-		case parseVar:
-			value = expand(value, m)
-			m[name] = value
-		case parseKey:
-			v := string(expand(value, m))
-			switch strings.ToLower(name) {
-			case "name":
-				pc.Name = v
-			case "description":
-				pc.Desc = v
-			case "version":
-				pc.Version = v
-			case "url":
-				pc.URL = v
-			case "libs":
-				// BUG(rjeczalik): Handle spaces in paths.
-				pc.Libs = flatsplit(v, " ")
-			case "libs.private":
-				// BUG(rjeczalik): Handle spaces in paths.
-				pc.LibsPrivate = flatsplit(v, " ")
-			case "cflags":
-				// BUG(rjeczalik): Handle spaces in paths.
-				pc.Cflags = flatsplit(v, " ")
-			}
-		}
 	}
 }
 
+func (pc *PC) set(keyword, v string) (err error) {
+	switch strings.ToLower(keyword) {
+	case "name":
+		pc.Name = v
+	case "description":
+		pc.Desc = v
+	case "version":
+		pc.Version = v
+	case "url":
+		pc.URL = v
+	case "requires":
+		pc.Requires, err = parseDeps(v)
+	case "requires.private":
+		pc.RequiresPrivate, err = parseDeps(v)
+	case "conflicts":
+		pc.Conflicts, err = parseDeps(v)
+	case "provides":
+		pc.Provides, err = parseDeps(v)
+	case "libs":
+		// BUG(rjeczalik): Handle spaces in paths.
+		pc.Libs = flatsplit(v, " ")
+	case "libs.private":
+		// BUG(rjeczalik): Handle spaces in paths.
+		pc.LibsPrivate = flatsplit(v, " ")
+	case "cflags":
+		// BUG(rjeczalik): Handle spaces in paths.
+		pc.Cflags = flatsplit(v, " ")
+	case "cflags.private":
+		// BUG(rjeczalik): Handle spaces in paths.
+		pc.CflagsPrivate = flatsplit(v, " ")
+	}
+	return
+}
+
 var defaultPaths []string
 
 // LookupPC TODO(rjeczalik): document
@@ -187,6 +188,7 @@ func LookupPC(pkg string) (*PC, error) {
 	var (
 		paths = strings.Split(os.Getenv("PKG_CONFIG_PATH"), string(os.PathListSeparator))
 		err   error
+		perr  error
 		pc    *PC
 		f     *os.File
 	)
@@ -200,7 +202,20 @@ func LookupPC(pkg string) (*PC, error) {
 				pc.File = file
 				return pc, nil
 			}
+			// A malformed file is reported rather than the paths, which
+			// do not have one.
+			if perr == nil {
+				perr = fmt.Errorf("%s: %v", file, err)
+			}
 		}
 	}
+	if perr != nil {
+		return nil, perr
+	}
 	return nil, err
 }
+
+// LookupPCContext is LookupPC, which fails if the context is done.
+func LookupPCContext(ctx context.Context, pkg string) (*PC, error) {
+	return withContext(LookupPC)(ctx, pkg)
+}
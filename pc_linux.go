@@ -1,5 +1,7 @@
 package pkgconfig
 
+import "path/filepath"
+
 func init() {
 	defaultPaths = append(defaultPaths,
 		"/usr/lib/pkgconfig",
@@ -7,4 +9,15 @@ func init() {
 		"/usr/local/lib/pkgconfig",
 		"/usr/local/share/pkgconfig",
 	)
+	defaultLibPaths = append(defaultLibPaths,
+		"/lib",
+		"/lib64",
+		"/usr/lib",
+		"/usr/lib64",
+		"/usr/local/lib",
+	)
+	// Multiarch directories, e.g. /usr/lib/x86_64-linux-gnu.
+	if dirs, err := filepath.Glob("/usr/lib/*-linux-gnu*"); err == nil {
+		defaultLibPaths = append(defaultLibPaths, dirs...)
+	}
 }
@@ -2,9 +2,11 @@ package pkgconfig
 
 import (
 	"bytes"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -122,14 +124,74 @@ func TestNewPCVars(t *testing.T) {
 	}
 }
 
+func TestNewPCComments(t *testing.T) {
+	raw := []byte("# Package Information\n\nprefix=/usr\n# libdir\nlibdir=${prefix}/lib\n\n" +
+		"# keywords\nName: A\nLibs: -L${libdir} -la\n")
+	pc, err := NewPC(bytes.NewBuffer(raw))
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	exp := &PC{Name: "A", Libs: []string{"-L/usr/lib", "-la"}}
+	if !reflect.DeepEqual(pc, exp) {
+		t.Errorf("expected pc=%+v; was %+v", exp, pc)
+	}
+	// Header-only and metapackage files have keywords only.
+	keywords := [...]string{
+		"# header\n\nName: foo\nVersion: 1\nCflags: -I/x\n",
+		"# header\nName: foo\nVersion: 1\nCflags: -I/x",
+		"Name: foo\n# prefix\nprefix=/x\nVersion: 1\nCflags: -I${prefix}\n",
+	}
+	exp = &PC{Name: "foo", Version: "1", Cflags: []string{"-I/x"}}
+	for i, cas := range keywords {
+		pc, err := NewPC(strings.NewReader(cas))
+		if err != nil {
+			t.Errorf("expected err=nil; was %q (i=%d)", err, i)
+			continue
+		}
+		if !reflect.DeepEqual(pc, exp) {
+			t.Errorf("expected pc=%+v; was %+v (i=%d)", exp, pc, i)
+		}
+	}
+}
+
+func TestNewPCDeps(t *testing.T) {
+	raw := []byte("\nName: A\nRequires: B >= 1.0, C\nRequires.private: D\n" +
+		"Conflicts: E < 2\nProvides: F = 1.1\nCflags.private: -DA_STATIC")
+	pc, err := NewPC(bytes.NewBuffer(raw))
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	exp := &PC{
+		Name:            "A",
+		Requires:        []Dep{{"B", ">=", "1.0"}, {Name: "C"}},
+		RequiresPrivate: []Dep{{Name: "D"}},
+		Conflicts:       []Dep{{"E", "<", "2"}},
+		Provides:        []Dep{{"F", "=", "1.1"}},
+		CflagsPrivate:   []string{"-DA_STATIC"},
+	}
+	if !reflect.DeepEqual(pc, exp) {
+		t.Errorf("expected pc=%+v; was %+v", exp, pc)
+	}
+	var buf bytes.Buffer
+	if _, err = (&PC{Requires: exp.Requires, Conflicts: exp.Conflicts, Libs: []string{"-la"},
+		Cflags: []string{"-ca"}}).WriteTo(&buf); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if s := "\nRequires: B >= 1.0, C\nConflicts: E < 2\nLibs: -la\nCflags: -ca\n"; buf.String() != s {
+		t.Errorf("expected buf=%q; was %q", s, buf.String())
+	}
+}
+
 func TestNewPCErr(t *testing.T) {
 	cases := [...][]byte{
 		[]byte(""),
 		[]byte(" "),
-		[]byte("libdir:\n\n"),
-		[]byte("libdir=/lib\nincludedir:\n\n"),
-		[]byte("libdir=/lib\nincludedir=/include\n\nCflags=-I${includedir}"),
-		[]byte("libdir=/lib\nincludedir=/include\n\nCflags:-I${includedir}\nName="),
+		[]byte("# comment\n\n"),
+		[]byte("libdir=/lib\nincludedir\n\n"),
+		[]byte("libdir=/lib\n=/include\n\nCflags: -I/include"),
+		[]byte("libdir=/lib\n\nCflags: -I/include\n: A"),
+		[]byte("\nName: A\nRequires: B >="),
+		[]byte("lib dir=/lib\n\nName: A"),
 	}
 	for i, cas := range cases {
 		if _, err := NewPC(bytes.NewBuffer(cas)); err == nil {
@@ -150,6 +212,18 @@ func TestPCWriteTo(t *testing.T) {
 		&PC{Name: "A", Desc: "B", Version: "C", URL: "D", Libs: []string{"-E"},
 			LibsPrivate: []string{"-F"}, Cflags: []string{"-G"}, File: "I"},
 		[]byte("\nName: A\nDescription: B\nVersion: C\nURL: D\nLibs.private: -F\nLibs: -E\nCflags: -G\n"),
+	}, {
+		&PC{Name: "A", Libs: []string{"-B"}, Cflags: []string{"-C"}, CflagsPrivate: []string{"-DD"}},
+		[]byte("\nName: A\nLibs: -B\nCflags.private: -DD\nCflags: -C\n"),
+	}, {
+		&PC{Libs: []string{"-A"}},
+		[]byte("\nLibs: -A\n"),
+	}, {
+		&PC{Name: "headers", Cflags: []string{"-B"}},
+		[]byte("\nName: headers\nCflags: -B\n"),
+	}, {
+		&PC{Name: "meta", Requires: []Dep{{Name: "A"}, {Name: "B"}}},
+		[]byte("\nName: meta\nRequires: A, B\n"),
 	}, {
 		&PC{Libs: []string{"-A", "", ""}, LibsPrivate: []string{"", "-B", ""},
 			Cflags: []string{"", "", "-C"}},
@@ -173,8 +247,6 @@ func TestPCWriteToErr(t *testing.T) {
 		{},
 		{Libs: []string{""}},
 		{Cflags: []string{""}},
-		{Libs: []string{"-A"}},
-		{Cflags: []string{"-B"}},
 		{Libs: []string{""}, Cflags: []string{""}},
 		{Libs: []string{""}, LibsPrivate: []string{"", ""}, Cflags: []string{"", "", ""}},
 	}
@@ -198,3 +270,21 @@ func TestLookupPC(t *testing.T) {
 		t.Errorf("expected pc=%+v; was %+v", expected, pc)
 	}
 }
+
+func TestLookupPCMalformed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	defer os.RemoveAll(dir)
+	if err = ioutil.WriteFile(filepath.Join(dir, "libfoo.pc"), []byte("Name foo\n"), 0644); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	defer os.Setenv("PKG_CONFIG_PATH", os.Getenv("PKG_CONFIG_PATH"))
+	if err = os.Setenv("PKG_CONFIG_PATH", dir+string(os.PathListSeparator)+"nonexistent"); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if _, err = LookupPC("libfoo"); err == nil || !strings.Contains(err.Error(), "malformed line") {
+		t.Errorf("expected malformed line error; was %v", err)
+	}
+}
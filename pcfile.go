@@ -0,0 +1,223 @@
+package pkgconfig
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// LineKind describes a type of a single line of a .pc file.
+type LineKind int
+
+// The kinds of lines a .pc file is composed of.
+const (
+	LineBlank LineKind = iota
+	LineComment
+	LineVar
+	LineKeyword
+)
+
+// Line is a single line of a .pc file. For variable and keyword lines the Name
+// and Value are set, with the value left unexpanded. For comment lines the
+// Value holds the comment text.
+type Line struct {
+	Kind  LineKind
+	Name  string
+	Value string
+	Num   int
+	raw   string
+}
+
+// String gives a textual representation of the line. Lines which were not
+// modified since they were read are given verbatim.
+func (l Line) String() string {
+	if l.raw != "" || l.Kind == LineBlank {
+		return l.raw
+	}
+	switch l.Kind {
+	case LineComment:
+		return l.Value
+	case LineVar:
+		return l.Name + "=" + l.Value
+	case LineKeyword:
+		if l.Value == "" {
+			return l.Name + ":"
+		}
+		return l.Name + ": " + l.Value
+	}
+	return l.raw
+}
+
+// PCFile is a document-level representation of a .pc file. In contrary to PC,
+// it keeps variables unexpanded and preserves ordering, comments and unknown
+// keywords, which makes it possible to edit a .pc file without destroying it.
+type PCFile struct {
+	Lines []Line
+}
+
+func isident(c byte) bool {
+	return isalnum(c) || c == '_' || c == '.' || c == '-'
+}
+
+func parseLine(s string, num int) (Line, error) {
+	l := Line{Num: num, raw: s}
+	p := strings.TrimSpace(s)
+	switch {
+	case p == "":
+		l.Kind, l.raw = LineBlank, ""
+		return l, nil
+	case p[0] == '#':
+		l.Kind, l.Value = LineComment, p
+		return l, nil
+	}
+	i := 0
+	for i < len(p) && isident(p[i]) {
+		i++
+	}
+	name := p[:i]
+	for i < len(p) && (p[i] == ' ' || p[i] == '\t') {
+		i++
+	}
+	if name == "" || i == len(p) || (p[i] != '=' && p[i] != ':') {
+		return l, fmt.Errorf("line %d: malformed line: %q", num, p)
+	}
+	l.Kind = LineVar
+	if p[i] == ':' {
+		l.Kind = LineKeyword
+	}
+	l.Name, l.Value = name, strings.TrimSpace(p[i+1:])
+	return l, nil
+}
+
+// ParsePCFile reads a .pc file from the given reader.
+func ParsePCFile(r io.Reader) (*PCFile, error) {
+	var (
+		f   = &PCFile{}
+		buf = bufio.NewScanner(r)
+	)
+	for n := 1; buf.Scan(); n++ {
+		l, err := parseLine(strings.TrimRight(buf.Text(), "\r"), n)
+		if err != nil {
+			return nil, err
+		}
+		f.Lines = append(f.Lines, l)
+	}
+	if err := buf.Err(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// WriteTo writes the .pc file to the given writer.
+func (f *PCFile) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	for _, l := range f.Lines {
+		buf.WriteString(l.String())
+		buf.WriteByte('\n')
+	}
+	return io.Copy(w, &buf)
+}
+
+func (f *PCFile) index(kind LineKind, name string) int {
+	for i, l := range f.Lines {
+		if l.Kind == kind && strings.EqualFold(l.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *PCFile) get(kind LineKind, name string) (string, bool) {
+	if i := f.index(kind, name); i != -1 {
+		return f.Lines[i].Value, true
+	}
+	return "", false
+}
+
+// Var gives the unexpanded value of the given variable.
+func (f *PCFile) Var(name string) (string, bool) {
+	return f.get(LineVar, name)
+}
+
+// Keyword gives the unexpanded value of the given keyword.
+func (f *PCFile) Keyword(name string) (string, bool) {
+	return f.get(LineKeyword, name)
+}
+
+func (f *PCFile) set(kind LineKind, name, value string) {
+	if i := f.index(kind, name); i != -1 {
+		f.Lines[i].Value, f.Lines[i].raw = value, ""
+		return
+	}
+	l := Line{Kind: kind, Name: name, Value: value}
+	// New variables go after the last variable, new keywords after the last
+	// line of the file.
+	i := len(f.Lines)
+	if kind == LineVar {
+		i = 0
+		for j, l := range f.Lines {
+			if l.Kind == LineVar {
+				i = j + 1
+			}
+		}
+	}
+	f.Lines = append(f.Lines, Line{})
+	copy(f.Lines[i+1:], f.Lines[i:])
+	f.Lines[i] = l
+}
+
+// SetVar sets the value of the given variable, adding it if it does not
+// exist yet.
+func (f *PCFile) SetVar(name, value string) {
+	f.set(LineVar, name, value)
+}
+
+// SetKeyword sets the value of the given keyword, adding it if it does not
+// exist yet.
+func (f *PCFile) SetKeyword(name, value string) {
+	f.set(LineKeyword, name, value)
+}
+
+func (f *PCFile) del(kind LineKind, name string) bool {
+	if i := f.index(kind, name); i != -1 {
+		f.Lines = append(f.Lines[:i], f.Lines[i+1:]...)
+		return true
+	}
+	return false
+}
+
+// DelVar removes the given variable. It reports whether the variable existed.
+func (f *PCFile) DelVar(name string) bool {
+	return f.del(LineVar, name)
+}
+
+// DelKeyword removes the given keyword. It reports whether the keyword existed.
+func (f *PCFile) DelKeyword(name string) bool {
+	return f.del(LineKeyword, name)
+}
+
+// PC expands the variables and gives the package configuration the file
+// describes. The vars are the builtin variables, like the ones LookupGopath
+// uses.
+func (f *PCFile) PC(vars map[string]string) (*PC, error) {
+	var (
+		pc = &PC{}
+		m  = make(map[string][]byte, len(vars))
+	)
+	for n, v := range vars {
+		m[n] = []byte(v)
+	}
+	for _, l := range f.Lines {
+		switch l.Kind {
+		case LineVar:
+			m[l.Name] = expand([]byte(l.Value), m)
+		case LineKeyword:
+			if err := pc.set(l.Name, string(expand([]byte(l.Value), m))); err != nil {
+				return nil, fmt.Errorf("line %d: %v", l.Num, err)
+			}
+		}
+	}
+	return pc, nil
+}
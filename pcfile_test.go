@@ -0,0 +1,106 @@
+package pkgconfig
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+var libgit2file = []byte(`# libgit2 pkg-config file
+libdir=${GOPATH}/lib/${GOOS}_${GOARCH}/libgit2
+includedir=${GOPATH}/include/libgit2
+
+Name: libgit2
+Description: The git library, take 2
+Version:   0.20.0
+X-Maintainer: rjeczalik
+Requires.private:
+Libs.private:  -lrt
+Libs: -L${libdir} -lgit2 -Wl,-rpath -Wl,$ORIGIN
+Cflags: -I${includedir}
+`)
+
+func TestPCFileRoundTrip(t *testing.T) {
+	f, err := ParsePCFile(bytes.NewBuffer(libgit2file))
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	var buf bytes.Buffer
+	if _, err = f.WriteTo(&buf); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if !bytes.Equal(buf.Bytes(), libgit2file) {
+		t.Errorf("expected buf=%q; was %q", libgit2file, buf.Bytes())
+	}
+}
+
+func TestPCFileEdit(t *testing.T) {
+	f, err := ParsePCFile(bytes.NewBuffer(libgit2file))
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if v, ok := f.Var("libdir"); !ok || v != "${GOPATH}/lib/${GOOS}_${GOARCH}/libgit2" {
+		t.Errorf("expected libdir to be unexpanded; was %q (ok=%v)", v, ok)
+	}
+	if v, ok := f.Keyword("x-maintainer"); !ok || v != "rjeczalik" {
+		t.Errorf(`expected X-Maintainer="rjeczalik"; was %q (ok=%v)`, v, ok)
+	}
+	f.SetKeyword("Version", "0.21.0")
+	f.SetKeyword("URL", "http://libgit2.github.com/")
+	f.SetVar("prefix", "${GOPATH}")
+	if !f.DelKeyword("Requires.private") {
+		t.Errorf("expected Requires.private to be deleted")
+	}
+	if f.DelVar("exec_prefix") {
+		t.Errorf("expected exec_prefix to not exist")
+	}
+	exp := bytes.Replace(libgit2file, []byte("Version:   0.20.0"), []byte("Version: 0.21.0"), 1)
+	exp = bytes.Replace(exp, []byte("Requires.private:\n"), nil, 1)
+	exp = bytes.Replace(exp, []byte("/libgit2\n\n"), []byte("/libgit2\nprefix=${GOPATH}\n\n"), 1)
+	exp = append(exp, "URL: http://libgit2.github.com/\n"...)
+	var buf bytes.Buffer
+	if _, err = f.WriteTo(&buf); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if !bytes.Equal(buf.Bytes(), exp) {
+		t.Errorf("expected buf=%q; was %q", exp, buf.Bytes())
+	}
+}
+
+func TestPCFilePC(t *testing.T) {
+	f, err := ParsePCFile(bytes.NewBuffer(libgit2file))
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	pc, err := f.PC(map[string]string{"GOPATH": "/go", "GOOS": "linux", "GOARCH": "amd64"})
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	exp, err := NewPCVars(bytes.NewBuffer(libgit2file),
+		map[string]string{"GOPATH": "/go", "GOOS": "linux", "GOARCH": "amd64"})
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if !reflect.DeepEqual(pc, exp) {
+		t.Errorf("expected pc=%+v; was %+v", exp, pc)
+	}
+}
+
+func TestParsePCFileErr(t *testing.T) {
+	cases := [...][]byte{
+		[]byte("libdir"),
+		[]byte("Name: A\n=B"),
+		[]byte("Name: A\n: B"),
+		[]byte("Name: A\nlib dir=B"),
+		[]byte("Name: A\nRequires: B >="),
+	}
+	for i, cas := range cases {
+		f, err := ParsePCFile(bytes.NewBuffer(cas))
+		if err == nil {
+			_, err = f.PC(nil)
+		}
+		if err == nil {
+			t.Errorf("expected err!=nil (i=%d)", i)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package pkgconfig
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"strings"
@@ -10,23 +12,51 @@ import (
 
 var errSkipGithub = errors.New("PKG_CONDIF_GITHUB not exported, skipping github.com lookup")
 
-func lookupGithubIfEnv(pkg string) (*PC, error) {
+func lookupGithubIfEnv(ctx context.Context, pkg string) (*PC, error) {
 	if os.Getenv("PKG_CONFIG_GITHUB") == "1" {
-		return LookupGithub(pkg)
+		return LookupGithubContext(ctx, pkg)
 	}
 	return nil, errSkipGithub
 }
 
 type pathLookupPair struct {
 	name string
-	fn   func(string) (*PC, error)
+	fn   func(context.Context, string) (*PC, error)
 }
 
 var lookups = []pathLookupPair{
-	{"$GOPATH", LookupGopath},
+	{"$GOPATH", LookupGopathContext},
 	{"github.com", lookupGithubIfEnv},
-	{"$PKG_CONFIG_PATH", LookupPC},
-	{"<autogenerated>", GenerateGopath},
+	{"$PKG_CONFIG_PATH", LookupPCContext},
+	{"<autogenerated>", withContext(GenerateGopath)},
+}
+
+// Source looks up the .pc file of a package by its name.
+type Source interface {
+	Lookup(ctx context.Context, pkg string) (*PC, error)
+}
+
+// SourceFunc is an adapter, which allows for using a function as a Source.
+type SourceFunc func(ctx context.Context, pkg string) (*PC, error)
+
+// Lookup calls fn(ctx, pkg).
+func (fn SourceFunc) Lookup(ctx context.Context, pkg string) (*PC, error) {
+	return fn(ctx, pkg)
+}
+
+// DefaultSource is the Source used by ResolveContext for a Pkg with neither
+// Source nor Lookup set.
+var DefaultSource Source = SourceFunc(DefaultLookupContext)
+
+// withContext makes the lookup, which does not block, fail if the context
+// is already done.
+func withContext(fn func(string) (*PC, error)) func(context.Context, string) (*PC, error) {
+	return func(ctx context.Context, pkg string) (*PC, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return fn(pkg)
+	}
 }
 
 type namedErrors map[string]error
@@ -40,10 +70,19 @@ func (ne namedErrors) Error() string {
 }
 
 // DefaultLookup TODO(rjeczalik): document
-func DefaultLookup(pkg string) (pc *PC, err error) {
+func DefaultLookup(pkg string) (*PC, error) {
+	return DefaultLookupContext(context.Background(), pkg)
+}
+
+// DefaultLookupContext is DefaultLookup, which stops as soon as the context
+// is done, giving the context's error.
+func DefaultLookupContext(ctx context.Context, pkg string) (pc *PC, err error) {
 	ne := make(map[string]error, len(lookups))
 	for _, lookup := range lookups {
-		if pc, err = lookup.fn(pkg); err == nil {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		if pc, err = lookup.fn(ctx, pkg); err == nil {
 			return
 		}
 		ne[lookup.name] = err
@@ -53,11 +92,19 @@ func DefaultLookup(pkg string) (pc *PC, err error) {
 
 // Pkg TODO(rjeczalik): document
 type Pkg struct {
-	Packages []string
-	Libs     bool
-	Cflags   bool
-	Lookup   func(string) (*PC, error)
-	pc       []*PC
+	Packages             []string
+	Libs                 bool
+	Cflags               bool
+	Static               bool
+	Validate             bool
+	PrintProvides        bool
+	PrintRequires        bool
+	PrintRequiresPrivate bool
+	Lookup               func(string) (*PC, error)
+	Source               Source
+	pc                   []*PC
+	top                  []*PC
+	private              map[*PC]bool
 }
 
 // NewPkgArgs TODO(rjeczalik): document
@@ -69,6 +116,16 @@ func NewPkgArgs(args []string) *Pkg {
 			pkg.Libs = true
 		case arg == "--cflags":
 			pkg.Cflags = true
+		case arg == "--static":
+			pkg.Static = true
+		case arg == "--validate":
+			pkg.Validate = true
+		case arg == "--print-provides":
+			pkg.PrintProvides = true
+		case arg == "--print-requires":
+			pkg.PrintRequires = true
+		case arg == "--print-requires-private":
+			pkg.PrintRequiresPrivate = true
 		case strings.HasPrefix(arg, "-"):
 		default:
 			pkg.Packages = append(pkg.Packages, arg)
@@ -77,58 +134,292 @@ func NewPkgArgs(args []string) *Pkg {
 	return pkg
 }
 
-// Resolve TODO(rjeczalik): document
+// Resolve looks up the requested packages and, recursively, all the packages
+// they require. A requirement which cannot be looked up by its name is
+// satisfied by a resolved package which provides it. It is an error
+// if any of the version constraints is not met or if any two of the
+// resolved packages conflict with each other.
+//
+// The packages are looked up with the Source, if set, otherwise with
+// the Lookup function; if neither is set, DefaultSource is used.
 func (pkg *Pkg) Resolve() error {
+	return pkg.ResolveContext(context.Background())
+}
+
+// ResolveContext is Resolve, which stops looking up packages as soon as
+// the context is done, giving the context's error.
+func (pkg *Pkg) ResolveContext(ctx context.Context) error {
+	return pkg.resolve(ctx, pkg.source(DefaultSource))
+}
+
+// source gives the Source of the Pkg, its Lookup function or, if neither is
+// set, the given default one.
+func (pkg *Pkg) source(def Source) Source {
+	switch {
+	case pkg.Source != nil:
+		return pkg.Source
+	case pkg.Lookup != nil:
+		return SourceFunc(withContext(pkg.Lookup))
+	}
+	return def
+}
+
+// resolve is ResolveContext, which looks up the packages with the source.
+func (pkg *Pkg) resolve(ctx context.Context, src Source) error {
 	if len(pkg.Packages) == 0 {
 		return ErrEmptyPC
 	}
-	lu := pkg.Lookup
-	if lu == nil {
-		lu = DefaultLookup
+	deps, err := parseDeps(strings.Join(pkg.Packages, " "))
+	if err != nil {
+		return err
 	}
-	var (
-		pc   = make([]*PC, 0, len(pkg.Packages))
-		dups = make(map[string]struct{})
-	)
-	for _, p := range pkg.Packages {
-		if _, ok := dups[p]; !ok {
-			pkg, err := lu(p)
-			if err != nil {
+	r := resolver{
+		lu: func(name string) (*PC, error) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			return src.Lookup(ctx, name)
+		},
+		pcs:     make(map[string]*PC),
+		private: make(map[*PC]bool),
+	}
+	var top []*PC
+	for _, dep := range deps {
+		pc, err := r.resolve(dep, false)
+		if err != nil {
+			return err
+		}
+		if pc != nil && !containsPC(top, pc) {
+			top = append(top, pc)
+		}
+	}
+	// Failed lookups are reported after resolving, so a cancelled one
+	// must not be mistaken for a missing package.
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	if err = r.provide(); err != nil {
+		return err
+	}
+	if err = r.conflicts(); err != nil {
+		return err
+	}
+	pkg.pc, pkg.top, pkg.private = r.pc, top, r.private
+	return nil
+}
+
+func containsPC(pcs []*PC, pc *PC) bool {
+	for _, p := range pcs {
+		if p == pc {
+			return true
+		}
+	}
+	return false
+}
+
+type resolver struct {
+	lu      func(string) (*PC, error)
+	pc      []*PC
+	pcs     map[string]*PC
+	private map[*PC]bool
+	virtual []Dep
+	errs    map[string]error
+}
+
+func (r *resolver) resolve(dep Dep, private bool) (*PC, error) {
+	if pc, ok := r.pcs[dep.Name]; ok {
+		if pc == nil {
+			// The package is either still being resolved, as it requires
+			// itself through the packages resolved since, or it failed to
+			// look up. The requirement is matched against the package or
+			// the ones which provide it after all the packages are resolved.
+			r.virtual = append(r.virtual, dep)
+			return nil, nil
+		}
+		if !private {
+			r.public(pc)
+		}
+		return pc, r.match(dep, pc)
+	}
+	r.pcs[dep.Name] = nil
+	pc, err := r.lu(dep.Name)
+	if err != nil {
+		if r.errs == nil {
+			r.errs = make(map[string]error)
+		}
+		r.errs[dep.Name] = err
+		r.virtual = append(r.virtual, dep)
+		return nil, nil
+	}
+	if err = r.match(dep, pc); err != nil {
+		return nil, err
+	}
+	r.pcs[dep.Name] = pc
+	r.pc = append(r.pc, pc)
+	if private {
+		r.private[pc] = true
+	}
+	for _, req := range pc.Requires {
+		// The package may have been required publicly in the meantime.
+		if _, err = r.resolve(req, r.private[pc]); err != nil {
+			return nil, err
+		}
+	}
+	for _, req := range pc.RequiresPrivate {
+		if _, err = r.resolve(req, true); err != nil {
+			return nil, err
+		}
+	}
+	return pc, nil
+}
+
+// public marks the package, which was required privately so far, and all
+// the packages it requires publicly as public.
+func (r *resolver) public(pc *PC) {
+	if !r.private[pc] {
+		return
+	}
+	delete(r.private, pc)
+	for _, req := range pc.Requires {
+		if pc := r.pcs[req.Name]; pc != nil {
+			r.public(pc)
+		}
+	}
+}
+
+func (r *resolver) match(dep Dep, pc *PC) error {
+	if !dep.Match(pc.Version) {
+		return fmt.Errorf("requested %q but version of %s is %s", dep, dep.Name, pc.Version)
+	}
+	return nil
+}
+
+// provided gives a version of the package name, which is provided
+// by the given pc.
+func provided(pc *PC, name string) (string, bool) {
+	for _, p := range pc.Provides {
+		if p.Name == name {
+			if p.Op == "=" {
+				return p.Version, true
+			}
+			return pc.Version, true
+		}
+	}
+	return "", false
+}
+
+func (r *resolver) provide() error {
+	for _, dep := range r.virtual {
+		if pc := r.pcs[dep.Name]; pc != nil {
+			if err := r.match(dep, pc); err != nil {
+				return err
+			}
+			continue
+		}
+		var found bool
+		for _, pc := range r.pc {
+			if version, ok := provided(pc, dep.Name); ok {
+				if !dep.Match(version) {
+					return fmt.Errorf("requested %q but %s provides version %s", dep, pc.Name, version)
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			if err, ok := r.errs[dep.Name]; ok {
 				return err
 			}
-			pc = append(pc, pkg)
-			dups[p] = struct{}{}
 		}
 	}
-	pkg.pc = pc
 	return nil
 }
 
+func (r *resolver) conflicts() error {
+	versions := func(self *PC, name string) (v []string) {
+		if pc := r.pcs[name]; pc != nil && pc != self {
+			v = append(v, pc.Version)
+		}
+		for _, pc := range r.pc {
+			if pc == self {
+				continue
+			}
+			if version, ok := provided(pc, name); ok {
+				v = append(v, version)
+			}
+		}
+		return
+	}
+	for _, pc := range r.pc {
+		for _, c := range pc.Conflicts {
+			for _, version := range versions(pc, c.Name) {
+				if c.Match(version) {
+					return fmt.Errorf("%s conflicts with %s %s (%q)", pc.Name, c.Name, version, c)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func writeDeps(buf *bytes.Buffer, deps []Dep) {
+	for _, dep := range deps {
+		buf.WriteString(dep.String())
+		buf.WriteByte('\n')
+	}
+}
+
+func (pkg Pkg) print(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	for _, pc := range pkg.top {
+		if pkg.PrintProvides {
+			writeDeps(&buf, []Dep{{Name: pc.Name, Op: "=", Version: pc.Version}})
+			writeDeps(&buf, pc.Provides)
+		}
+		if pkg.PrintRequires {
+			writeDeps(&buf, pc.Requires)
+		}
+		if pkg.PrintRequiresPrivate {
+			writeDeps(&buf, pc.RequiresPrivate)
+		}
+	}
+	return io.Copy(w, &buf)
+}
+
 // WriteTo TODO(rjeczalik): document
 func (pkg Pkg) WriteTo(w io.Writer) (int64, error) {
+	if pkg.PrintProvides || pkg.PrintRequires || pkg.PrintRequiresPrivate {
+		return pkg.print(w)
+	}
 	var (
 		dups = make(map[string]struct{})
 		buf  bytes.Buffer
 	)
+	write := func(flags []string) {
+		for _, flag := range flags {
+			if _, ok := dups[flag]; !ok {
+				buf.WriteString(flag)
+				buf.WriteByte(' ')
+				dups[flag] = struct{}{}
+			}
+		}
+	}
 	if pkg.Cflags {
 		for _, pc := range pkg.pc {
-			for _, cflag := range pc.Cflags {
-				if _, ok := dups[cflag]; !ok {
-					buf.WriteString(cflag)
-					buf.WriteByte(' ')
-					dups[cflag] = struct{}{}
-				}
+			write(pc.Cflags)
+			if pkg.Static {
+				write(pc.CflagsPrivate)
 			}
 		}
 	}
 	if pkg.Libs {
 		for _, pc := range pkg.pc {
-			for _, lib := range pc.Libs {
-				if _, ok := dups[lib]; !ok {
-					buf.WriteString(lib)
-					buf.WriteByte(' ')
-					dups[lib] = struct{}{}
-				}
+			if pkg.private[pc] && !pkg.Static {
+				continue
+			}
+			write(pc.Libs)
+			if pkg.Static {
+				write(pc.LibsPrivate)
 			}
 		}
 	}
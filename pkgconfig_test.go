@@ -2,6 +2,7 @@ package pkgconfig
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"os"
 	"reflect"
@@ -30,6 +31,12 @@ func TestNewPkgArgs(t *testing.T) {
 	}, {
 		[]string{"--cflags", "--libs", "--libs.private", "-XD", "lib1", "lib2", "lib3"},
 		&Pkg{Cflags: true, Libs: true, Packages: []string{"lib1", "lib2", "lib3"}},
+	}, {
+		[]string{"--static", "--cflags", "--libs", "lib1"},
+		&Pkg{Static: true, Cflags: true, Libs: true, Packages: []string{"lib1"}},
+	}, {
+		[]string{"--print-provides", "--print-requires", "--print-requires-private", "lib1"},
+		&Pkg{PrintProvides: true, PrintRequires: true, PrintRequiresPrivate: true, Packages: []string{"lib1"}},
 	}}
 	for i, cas := range cases {
 		if pkg := NewPkgArgs(cas.args); pkg == nil || !reflect.DeepEqual(pkg, cas.exp) {
@@ -197,3 +204,250 @@ func TestPkgWriteTo(t *testing.T) {
 		}
 	}
 }
+
+func TestPkgResolveDeps(t *testing.T) {
+	all := map[string]*PC{
+		"A": &PC{Name: "A", Version: "1.0", Requires: []Dep{{"B", ">=", "2.0"}}},
+		"B": &PC{Name: "B", Version: "2.1", RequiresPrivate: []Dep{{Name: "C"}}},
+		"C": &PC{Name: "C", Version: "0.1", Requires: []Dep{{Name: "A"}}},
+		"D": &PC{Name: "D", Version: "3.0", Requires: []Dep{{"virtual", ">", "1"}}},
+		"E": &PC{Name: "E", Version: "1.5", Provides: []Dep{{"virtual", "=", "1.2"}}},
+		"F": &PC{Name: "F", Version: "1.0", Conflicts: []Dep{{"B", "<", "3.0"}}},
+		"G": &PC{Name: "G", Version: "1.0", Conflicts: []Dep{{"virtual", "<=", "1.0"}}},
+	}
+	lu := func(pkg string) (*PC, error) {
+		pc, ok := all[pkg]
+		if !ok {
+			return nil, errors.New("not found")
+		}
+		return pc, nil
+	}
+	cases := []struct {
+		pkgs []string
+		pc   []*PC
+	}{{
+		[]string{"A"},
+		[]*PC{all["A"], all["B"], all["C"]},
+	}, {
+		[]string{"C", "A"},
+		[]*PC{all["C"], all["A"], all["B"]},
+	}, {
+		[]string{"A", ">=", "1.0"},
+		[]*PC{all["A"], all["B"], all["C"]},
+	}, {
+		[]string{"D", "E"},
+		[]*PC{all["D"], all["E"]},
+	}, {
+		[]string{"E", "G"},
+		[]*PC{all["E"], all["G"]},
+	}}
+	for i, cas := range cases {
+		pkg := &Pkg{Packages: cas.pkgs, Lookup: lu}
+		if err := pkg.Resolve(); err != nil {
+			t.Errorf("expected err=nil; was %q (i=%d)", err, i)
+			continue
+		}
+		if !reflect.DeepEqual(pkg.pc, cas.pc) {
+			t.Errorf("expected pkg.pc=%+v; was %+v (i=%d)", cas.pc, pkg.pc, i)
+		}
+	}
+	casesErr := [][]string{
+		{"A", ">", "1.0"},
+		{"B", "<", "2"},
+		{"D"},
+		{"virtual"},
+		{"A", "F"},
+		{"D", "E", "virtual", "<", "1.0"},
+		{"G", "E", "virtual", "=", "1.0"},
+	}
+	for i, cas := range casesErr {
+		pkg := &Pkg{Packages: cas, Lookup: lu}
+		if err := pkg.Resolve(); err == nil {
+			t.Errorf("expected err!=nil (i=%d)", i)
+		}
+	}
+}
+
+func TestPkgWriteToPrivate(t *testing.T) {
+	all := map[string]*PC{
+		"A": &PC{Libs: []string{"-la"}, Cflags: []string{"-ca"}, RequiresPrivate: []Dep{{Name: "B"}}},
+		"B": &PC{Libs: []string{"-lb"}, Cflags: []string{"-cb"}},
+	}
+	pkg := &Pkg{
+		Packages: []string{"A"},
+		Libs:     true,
+		Cflags:   true,
+		Lookup:   func(pkg string) (*PC, error) { return all[pkg], nil },
+	}
+	if err := pkg.Resolve(); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	var buf bytes.Buffer
+	if _, err := pkg.WriteTo(&buf); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if exp := "-ca -cb -la\n"; buf.String() != exp {
+		t.Errorf("expected buf=%q; was %q", exp, buf.String())
+	}
+}
+
+func TestPkgWriteToPrivatePublic(t *testing.T) {
+	all := map[string]*PC{
+		"X": &PC{Libs: []string{"-lx"}, Requires: []Dep{{Name: "Z"}}},
+		"Y": &PC{Libs: []string{"-ly"}, RequiresPrivate: []Dep{{Name: "Z"}}},
+		"Z": &PC{Libs: []string{"-lz"}, Requires: []Dep{{Name: "W"}}},
+		"W": &PC{Libs: []string{"-lw"}},
+	}
+	// Z is required publicly by X, whichever reaches it first.
+	cases := [...]struct {
+		pkgs []string
+		exp  string
+	}{
+		{[]string{"X", "Y"}, "-lx -lz -lw -ly\n"},
+		{[]string{"Y", "X"}, "-ly -lz -lw -lx\n"},
+	}
+	var buf bytes.Buffer
+	for i, cas := range cases {
+		buf.Reset()
+		pkg := &Pkg{
+			Packages: cas.pkgs,
+			Libs:     true,
+			Lookup:   func(pkg string) (*PC, error) { return all[pkg], nil },
+		}
+		if err := pkg.Resolve(); err != nil {
+			t.Errorf("expected err=nil; was %q (i=%d)", err, i)
+			continue
+		}
+		if _, err := pkg.WriteTo(&buf); err != nil {
+			t.Errorf("expected err=nil; was %q (i=%d)", err, i)
+			continue
+		}
+		if buf.String() != cas.exp {
+			t.Errorf("expected buf=%q; was %q (i=%d)", cas.exp, buf.String(), i)
+		}
+	}
+}
+
+func TestPkgWriteToStatic(t *testing.T) {
+	all := map[string]*PC{
+		"A": &PC{
+			Libs:            []string{"-la"},
+			LibsPrivate:     []string{"-lm"},
+			Cflags:          []string{"-ca"},
+			CflagsPrivate:   []string{"-DA_STATIC"},
+			RequiresPrivate: []Dep{{Name: "B"}},
+		},
+		"B": &PC{Libs: []string{"-lb"}, Cflags: []string{"-cb"}, CflagsPrivate: []string{"-DB_STATIC"}},
+	}
+	cases := [...]struct {
+		static bool
+		exp    string
+	}{
+		{false, "-ca -cb -la\n"},
+		{true, "-ca -DA_STATIC -cb -DB_STATIC -la -lm -lb\n"},
+	}
+	var buf bytes.Buffer
+	for i, cas := range cases {
+		buf.Reset()
+		pkg := &Pkg{
+			Packages: []string{"A"},
+			Libs:     true,
+			Cflags:   true,
+			Static:   cas.static,
+			Lookup:   func(pkg string) (*PC, error) { return all[pkg], nil },
+		}
+		if err := pkg.Resolve(); err != nil {
+			t.Errorf("expected err=nil; was %q (i=%d)", err, i)
+			continue
+		}
+		if _, err := pkg.WriteTo(&buf); err != nil {
+			t.Errorf("expected err=nil; was %q (i=%d)", err, i)
+			continue
+		}
+		if buf.String() != cas.exp {
+			t.Errorf("expected buf=%q; was %q (i=%d)", cas.exp, buf.String(), i)
+		}
+	}
+}
+
+func TestPkgWriteToPrint(t *testing.T) {
+	pc := &PC{
+		Name:            "A",
+		Version:         "1.0",
+		Requires:        []Dep{{"B", ">=", "2.0"}, {Name: "C"}},
+		RequiresPrivate: []Dep{{Name: "D"}},
+		Provides:        []Dep{{"E", "=", "1.1"}},
+	}
+	cases := [...]struct {
+		pkg *Pkg
+		exp string
+	}{
+		{&Pkg{PrintProvides: true}, "A = 1.0\nE = 1.1\n"},
+		{&Pkg{PrintRequires: true}, "B >= 2.0\nC\n"},
+		{&Pkg{PrintRequiresPrivate: true}, "D\n"},
+	}
+	var buf bytes.Buffer
+	for i, cas := range cases {
+		buf.Reset()
+		cas.pkg.pc, cas.pkg.top = []*PC{pc}, []*PC{pc}
+		if _, err := cas.pkg.WriteTo(&buf); err != nil {
+			t.Errorf("expected err=nil; was %q (i=%d)", err, i)
+			continue
+		}
+		if buf.String() != cas.exp {
+			t.Errorf("expected buf=%q; was %q (i=%d)", cas.exp, buf.String(), i)
+		}
+	}
+}
+
+func TestPkgResolveContext(t *testing.T) {
+	all := map[string]*PC{
+		"A": &PC{Libs: []string{"-la"}, Requires: []Dep{{Name: "B"}}},
+		"B": &PC{Libs: []string{"-lb"}},
+	}
+	var looked []string
+	pkg := &Pkg{
+		Packages: []string{"A"},
+		Libs:     true,
+		Lookup: func(pkg string) (*PC, error) {
+			t.Errorf("expected Source to take precedence over Lookup; looked up %s", pkg)
+			return nil, errors.New("unexpected lookup")
+		},
+		Source: SourceFunc(func(ctx context.Context, pkg string) (*PC, error) {
+			looked = append(looked, pkg)
+			return all[pkg], nil
+		}),
+	}
+	if err := pkg.ResolveContext(context.Background()); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if exp := []string{"A", "B"}; !reflect.DeepEqual(looked, exp) {
+		t.Errorf("expected looked=%v; was %v", exp, looked)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	looked = nil
+	pkg.Source = SourceFunc(func(ctx context.Context, pkg string) (*PC, error) {
+		looked = append(looked, pkg)
+		// A failed lookup of a cancelled request must not be reported
+		// as a missing package.
+		cancel()
+		return nil, ctx.Err()
+	})
+	if err := pkg.ResolveContext(ctx); err != context.Canceled {
+		t.Errorf("expected err=%q; was %v", context.Canceled, err)
+	}
+	if err := pkg.ResolveContext(ctx); err != context.Canceled {
+		t.Errorf("expected err=%q; was %v", context.Canceled, err)
+	}
+	if exp := []string{"A"}; !reflect.DeepEqual(looked, exp) {
+		t.Errorf("expected looked=%v; was %v", exp, looked)
+	}
+}
+
+func TestDefaultLookupContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := DefaultLookupContext(ctx, "libfoo"); err != context.Canceled {
+		t.Errorf("expected err=%q; was %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,153 @@
+package pkgconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ProxyEnv is the environment variable, which lists the mirrors archives
+// are downloaded from, modeled on GOPROXY:
+//
+//	PKG_CONFIG_GO_PROXY=https://cdeps.example.com,direct
+const ProxyEnv = "PKG_CONFIG_GO_PROXY"
+
+// The keywords, which are allowed on the list of mirrors along with URLs.
+const (
+	// ProxyDirect stands for the project's host.
+	ProxyDirect = "direct"
+	// ProxyOff disallows downloading from any of the following mirrors.
+	ProxyOff = "off"
+)
+
+// ProxyLayout is the URL template of archives within a mirror, where {base}
+// is the mirror URL. The sibling checksum and signature assets are expected
+// next to the archive.
+const ProxyLayout = "{base}{proj}/{tag}/{lib}{ext}"
+
+// ParseProxy splits the comma-separated list of mirrors, skipping empty
+// entries. Each one is expected to be a keyword or an http or https URL.
+func ParseProxy(s string) []string {
+	var proxy []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			proxy = append(proxy, p)
+		}
+	}
+	return proxy
+}
+
+// validMirror tells whether the mirror is an http or https URL.
+func validMirror(m string) bool {
+	u, err := url.Parse(m)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// errProxyOff is returned for the downloads, which are disallowed by the list
+// of mirrors.
+var errProxyOff = errors.New("downloading disabled by " + ProxyEnv + "=" + ProxyOff)
+
+// archiveExt gives the one of the ArchiveExts the url ends with or, if none,
+// empty string.
+func archiveExt(url string) string {
+	for _, ext := range ArchiveExts {
+		if strings.HasSuffix(url, ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+// proxy gives the list of mirrors of the Fetcher.
+func (f *Fetcher) proxy() []string {
+	if len(f.Proxy) == 0 {
+		return []string{ProxyDirect}
+	}
+	return f.Proxy
+}
+
+// mirror gives the location of the archive of the lock entry within
+// the mirror.
+func mirror(m string, e *LockEntry) string {
+	name, _ := splittag(e.Lib)
+	return Host{Template: ProxyLayout}.URL(strings.TrimSuffix(m, "/")+"/", e.Proj, e.Tag, name, archiveExt(e.URL))
+}
+
+// proxied calls fn with the location of the archive of the lock entry within
+// each of the mirrors in order, until it does not fail with notFoundError.
+// It gives the location fn succeeded with.
+func (f *Fetcher) proxied(e *LockEntry, fn func(url string) error) (string, error) {
+	var notfound error
+	for _, m := range f.proxy() {
+		u := e.URL
+		switch m {
+		case ProxyDirect:
+		case ProxyOff:
+			if notfound != nil {
+				return "", notfound
+			}
+			return "", fmt.Errorf("%s: %w", e.URL, errProxyOff)
+		default:
+			if !validMirror(m) {
+				return "", fmt.Errorf("invalid mirror %q in $%s", m, ProxyEnv)
+			}
+			u = mirror(m, e)
+		}
+		err := fn(u)
+		if err == nil {
+			return u, nil
+		}
+		if _, ok := err.(notFoundError); !ok {
+			return "", err
+		}
+		notfound = err
+	}
+	return "", notfound
+}
+
+// source gives the location of the archive of the lock entry within the first
+// of the mirrors or empty string, if downloading is off.
+func (f *Fetcher) source(e *LockEntry) string {
+	switch m := f.proxy()[0]; m {
+	case ProxyDirect:
+		return e.URL
+	case ProxyOff:
+		return ""
+	default:
+		return mirror(m, e)
+	}
+}
+
+// downloadProxy downloads the archive of the lock entry from the first
+// of the mirrors, which has it. It gives the location it was downloaded from.
+func (f *Fetcher) downloadProxy(ctx context.Context, e *LockEntry, name string) (file, src string, err error) {
+	src, err = f.proxied(e, func(url string) (err error) {
+		file, err = f.download(ctx, url, name)
+		return err
+	})
+	return file, src, err
+}
+
+// ProxyHandler serves the archives from the directory, which has the ProxyLayout,
+// so it can be used as a mirror. Hidden files are not served.
+func ProxyHandler(dir string) http.Handler {
+	fs := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		for _, s := range strings.Split(path.Clean("/"+r.URL.Path), "/") {
+			if strings.HasPrefix(s, ".") {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		fs.ServeHTTP(w, r)
+	})
+}
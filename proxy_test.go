@@ -0,0 +1,185 @@
+package pkgconfig
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseProxy(t *testing.T) {
+	cases := [...]struct {
+		s   string
+		exp []string
+	}{
+		{"", nil},
+		{"direct", []string{"direct"}},
+		{"https://a.example.com, http://b.example.com/cdeps/,direct", []string{"https://a.example.com",
+			"http://b.example.com/cdeps/", "direct"}},
+		{",off,,", []string{"off"}},
+	}
+	for i, cas := range cases {
+		if proxy := ParseProxy(cas.s); !reflect.DeepEqual(proxy, cas.exp) {
+			t.Errorf("expected proxy=%q; was %q (i=%d)", cas.exp, proxy, i)
+		}
+	}
+}
+
+// mirrordir creates a directory with the libfoo archive in the ProxyLayout.
+func mirrordir(t *testing.T, p []byte) string {
+	dir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	proj := filepath.Join(dir, "github.com", "user", "proj", DefaultTag)
+	if err = os.MkdirAll(proj, 0755); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(proj, "libfoo.zip"), p, 0644); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, ".secret"), []byte("secret"), 0644); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	return dir
+}
+
+func TestFetcherProxy(t *testing.T) {
+	p := libfoozip(t)
+	dir := mirrordir(t, p)
+	defer os.RemoveAll(dir)
+	var n int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		if r.URL.Path != libfooPath {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(p)
+	}))
+	defer srv.Close()
+	mirror := httptest.NewServer(ProxyHandler(dir))
+	defer mirror.Close()
+	empty := httptest.NewServer(http.NotFoundHandler())
+	defer empty.Close()
+	cases := [...]struct {
+		proxy  []string
+		direct bool
+		err    string
+	}{
+		{[]string{mirror.URL}, false, ""},
+		{[]string{mirror.URL + "/", ProxyOff}, false, ""},
+		{[]string{empty.URL, ProxyDirect}, true, ""},
+		{[]string{ProxyDirect, mirror.URL}, true, ""},
+		{[]string{ProxyOff, ProxyDirect}, false, errProxyOff.Error()},
+		{[]string{empty.URL, ProxyOff, ProxyDirect}, false, "not found"},
+		{[]string{"ftp://mirror.example.com"}, false, "invalid mirror"},
+	}
+	for i, cas := range cases {
+		func() {
+			gopath, restore := tempgopath(t)
+			defer restore()
+			n = 0
+			f := &Fetcher{Client: srv.Client(), BaseURL: srv.URL + "/", Proxy: cas.proxy}
+			e, err := f.Get("libfoo", "github.com/user/proj")
+			if cas.err != "" {
+				if err == nil || !strings.Contains(err.Error(), cas.err) {
+					t.Errorf("expected err=%q; was %v (i=%d)", cas.err, err, i)
+				}
+				if n != 0 {
+					t.Errorf("expected no direct requests; was %d (i=%d)", n, i)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("expected err=nil; was %q (i=%d)", err, i)
+				return
+			}
+			if direct := n != 0; direct != cas.direct {
+				t.Errorf("expected direct=%t; was %t (i=%d)", cas.direct, direct, i)
+			}
+			// Lock entries are independent of the mirrors used.
+			if exp := srv.URL + libfooPath; e.URL != exp {
+				t.Errorf("expected e.URL=%q; was %q (i=%d)", exp, e.URL, i)
+			}
+			if err = existFile(filepath.Join(gopath, "include", "libfoo", "foo.h")); err != nil {
+				t.Errorf("expected err=nil; was %q (i=%d)", err, i)
+			}
+		}()
+	}
+}
+
+func TestFetcherProxyOffCached(t *testing.T) {
+	_, restore := tempgopath(t)
+	defer restore()
+	cachedir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	defer os.RemoveAll(cachedir)
+	p := libfoozip(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != libfooPath {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(p)
+	}))
+	defer srv.Close()
+	f := &Fetcher{Client: srv.Client(), BaseURL: srv.URL + "/", Cache: &Cache{Dir: cachedir}}
+	if _, err = f.Get("libfoo", "github.com/user/proj"); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	f.Proxy = []string{ProxyOff}
+	if _, err = f.Get("libfoo", "github.com/user/proj"); err != nil {
+		t.Errorf("expected the cached archive to be installed; was %q", err)
+	}
+	if _, err = f.Get("libbar", "github.com/user/proj"); !errors.Is(err, errProxyOff) {
+		t.Errorf("expected err=%q; was %v", errProxyOff, err)
+	}
+}
+
+func TestProxyHandler(t *testing.T) {
+	p := libfoozip(t)
+	dir := mirrordir(t, p)
+	defer os.RemoveAll(dir)
+	srv := httptest.NewServer(ProxyHandler(dir))
+	defer srv.Close()
+	cases := [...]struct {
+		method string
+		path   string
+		rng    string
+		code   int
+	}{
+		{http.MethodGet, "/github.com/user/proj/pkg-config/libfoo.zip", "", http.StatusOK},
+		{http.MethodHead, "/github.com/user/proj/pkg-config/libfoo.zip", "", http.StatusOK},
+		{http.MethodGet, "/github.com/user/proj/pkg-config/libfoo.zip", "bytes=10-", http.StatusPartialContent},
+		{http.MethodGet, "/github.com/user/proj/pkg-config/libbar.zip", "", http.StatusNotFound},
+		{http.MethodGet, "/.secret", "", http.StatusNotFound},
+		{http.MethodGet, "/github.com/../.secret", "", http.StatusNotFound},
+		{http.MethodPost, "/github.com/user/proj/pkg-config/libfoo.zip", "", http.StatusMethodNotAllowed},
+	}
+	for i, cas := range cases {
+		req, err := http.NewRequest(cas.method, srv.URL+cas.path, nil)
+		if err != nil {
+			t.Fatalf("expected err=nil; was %q (i=%d)", err, i)
+		}
+		if cas.rng != "" {
+			req.Header.Set("Range", cas.rng)
+		}
+		res, err := srv.Client().Do(req)
+		if err != nil {
+			t.Errorf("expected err=nil; was %q (i=%d)", err, i)
+			continue
+		}
+		res.Body.Close()
+		if res.StatusCode != cas.code {
+			t.Errorf("expected code=%d; was %d (i=%d)", cas.code, res.StatusCode, i)
+		}
+	}
+}
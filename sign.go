@@ -0,0 +1,194 @@
+package pkgconfig
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// KeysFileName is the name of a file, committed in a consuming repository,
+// which lists public keys trusted to sign library archives of a project.
+// Each line has the following format:
+//
+//	github.com/USER/PROJECT ed25519:BASE64
+const KeysFileName = "cdeps.keys"
+
+// ErrSignature is returned when a signature of an archive does not verify
+// with any of the trusted keys.
+var ErrSignature = errors.New("invalid signature")
+
+const keyPrefix = "ed25519:"
+
+// ParsePublicKey parses a public key in the "ed25519:BASE64" format.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	if !strings.HasPrefix(s, keyPrefix) {
+		return nil, fmt.Errorf("unsupported key %q", s)
+	}
+	p, err := base64.StdEncoding.DecodeString(s[len(keyPrefix):])
+	if err != nil || len(p) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("malformed key %q", s)
+	}
+	return ed25519.PublicKey(p), nil
+}
+
+// FormatPublicKey gives the "ed25519:BASE64" representation of the key.
+func FormatPublicKey(key ed25519.PublicKey) string {
+	return keyPrefix + base64.StdEncoding.EncodeToString(key)
+}
+
+// ReadKeysFile reads trusted public keys from the given cdeps.keys file.
+func ReadKeysFile(file string) (map[string][]ed25519.PublicKey, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var (
+		keys = make(map[string][]ed25519.PublicKey)
+		buf  = bufio.NewScanner(f)
+	)
+	for n := 1; buf.Scan(); n++ {
+		v := strings.Fields(buf.Text())
+		if len(v) == 0 || strings.HasPrefix(v[0], "#") {
+			continue
+		}
+		if len(v) != 2 {
+			return nil, fmt.Errorf("%s:%d: malformed line", file, n)
+		}
+		key, err := ParsePublicKey(v[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", file, n, err)
+		}
+		keys[v[0]] = append(keys[v[0]], key)
+	}
+	return keys, buf.Err()
+}
+
+// GenerateKey creates a new key pair for signing archives. The private key
+// is written to the given file, the public key is returned.
+func GenerateKey(file string) (ed25519.PublicKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	s := base64.StdEncoding.EncodeToString(priv.Seed()) + "\n"
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.WriteString(f, s)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pub, nil
+}
+
+// ReadPrivateKey reads a private key written by GenerateKey.
+func ReadPrivateKey(file string) (ed25519.PrivateKey, error) {
+	p, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(p)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("malformed private key %s", file)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// SignFile writes a detached signature of the given file to the file.sig,
+// which should be published alongside the archive.
+func SignFile(file string, key ed25519.PrivateKey) error {
+	p, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(key, p)) + "\n"
+	return ioutil.WriteFile(file+".sig", []byte(sig), 0644)
+}
+
+func (f *Fetcher) keys(proj string) ([]ed25519.PublicKey, error) {
+	keys := f.Keys[proj]
+	if f.KeysFile != "" {
+		m, err := ReadKeysFile(f.KeysFile)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		keys = append(keys[:len(keys):len(keys)], m[proj]...)
+	}
+	return keys, nil
+}
+
+// verifySig checks the signature of the downloaded file with the keys
+// trusted for the project. It's a nop if there are no such keys. A signature
+// of a cached archive is reused, if it was cached alongside.
+func (f *Fetcher) verifySig(ctx context.Context, file, url, proj, sum string) error {
+	keys, err := f.keys(proj)
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+	var p []byte
+	if f.Cache != nil {
+		p = f.Cache.sig(sum)
+	}
+	if p == nil {
+		switch {
+		case f.Offline:
+			return fmt.Errorf("no cached signature for %s in offline mode", url)
+		case url == "":
+			return fmt.Errorf("no cached signature for the %s archive: %w", proj, errProxyOff)
+		}
+		if p, err = f.fetchSig(ctx, url); err != nil {
+			return err
+		}
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(p)))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("malformed signature %s.sig", url)
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if ed25519.Verify(key, data, sig) {
+			if f.Cache != nil {
+				f.Cache.putSig(sum, p)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: %w", url, ErrSignature)
+}
+
+func (f *Fetcher) fetchSig(ctx context.Context, url string) (p []byte, err error) {
+	err = f.retry(ctx, func() error {
+		p, err = f.readSig(ctx, url)
+		return err
+	})
+	return p, err
+}
+
+func (f *Fetcher) readSig(ctx context.Context, url string) ([]byte, error) {
+	res, err := f.httpGet(ctx, url+".sig")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch signature: %w", newStatusError(url+".sig", res))
+	}
+	return ioutil.ReadAll(io.LimitReader(res.Body, 4096))
+}
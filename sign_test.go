@@ -0,0 +1,140 @@
+package pkgconfig
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	defer os.RemoveAll(dir)
+	keyfile := filepath.Join(dir, "key")
+	pub, err := GenerateKey(keyfile)
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if _, err = GenerateKey(keyfile); err == nil {
+		t.Error("expected GenerateKey to not overwrite existing key")
+	}
+	priv, err := ReadPrivateKey(keyfile)
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if !pub.Equal(priv.Public()) {
+		t.Error("expected public keys to be equal")
+	}
+	parsed, err := ParsePublicKey(FormatPublicKey(pub))
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if !pub.Equal(parsed) {
+		t.Error("expected parsed key to be equal")
+	}
+	for i, s := range []string{"", "ed25519:", "ed25519:AAAA", "rsa:" + FormatPublicKey(pub)[8:]} {
+		if _, err := ParsePublicKey(s); err == nil {
+			t.Errorf("expected err!=nil (i=%d)", i)
+		}
+	}
+}
+
+func TestFetcherSignature(t *testing.T) {
+	dir, restore := tempgopath(t)
+	defer restore()
+	p := libfoozip(t)
+	archive := filepath.Join(dir, "libfoo.zip")
+	if err := ioutil.WriteFile(archive, p, 0644); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	other, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if err = SignFile(archive, priv); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	sig, err := ioutil.ReadFile(archive + ".sig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(libfooPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(p)
+	})
+	mux.HandleFunc(libfooPath+".sig", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(sig)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	unsigned := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != libfooPath {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(p)
+	}))
+	defer unsigned.Close()
+	keysfile := filepath.Join(dir, KeysFileName)
+	if err = ioutil.WriteFile(keysfile, []byte("# trusted keys\ngithub.com/user/proj "+
+		FormatPublicKey(pub)+"\n"), 0644); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	const proj = "github.com/user/proj"
+	cases := [...]struct {
+		srv  *httptest.Server
+		keys map[string][]ed25519.PublicKey
+		file string
+		ok   bool
+	}{
+		{srv, map[string][]ed25519.PublicKey{proj: {pub}}, "", true},
+		{srv, map[string][]ed25519.PublicKey{proj: {other, pub}}, "", true},
+		{srv, nil, keysfile, true},
+		{srv, nil, filepath.Join(dir, "nonexistent"), true},
+		{unsigned, nil, "", true},
+		{unsigned, map[string][]ed25519.PublicKey{"github.com/user/other": {pub}}, "", true},
+		{srv, map[string][]ed25519.PublicKey{proj: {other}}, "", false},
+		{unsigned, map[string][]ed25519.PublicKey{proj: {pub}}, "", false},
+		{unsigned, nil, keysfile, false},
+	}
+	for i, cas := range cases {
+		os.RemoveAll(filepath.Join(dir, "include"))
+		f := &Fetcher{
+			Client:   cas.srv.Client(),
+			BaseURL:  cas.srv.URL + "/",
+			Keys:     cas.keys,
+			KeysFile: cas.file,
+		}
+		_, err := f.Lookup("libfoo", proj)
+		if cas.ok && err != nil {
+			t.Errorf("expected err=nil; was %q (i=%d)", err, i)
+		}
+		if !cas.ok {
+			if err == nil {
+				t.Errorf("expected err!=nil (i=%d)", i)
+			}
+			if existDir(filepath.Join(dir, "include")) == nil {
+				t.Errorf("expected nothing to be installed (i=%d)", i)
+			}
+		}
+	}
+	f := &Fetcher{
+		Client:  srv.Client(),
+		BaseURL: srv.URL + "/",
+		Keys:    map[string][]ed25519.PublicKey{proj: {other}},
+	}
+	if _, err = f.Lookup("libfoo", proj); !errors.Is(err, ErrSignature) {
+		t.Errorf("expected err=ErrSignature; was %v", err)
+	}
+}
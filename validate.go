@@ -0,0 +1,175 @@
+package pkgconfig
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Problem describes a single issue found by Pkg.Check.
+type Problem struct {
+	Package string
+	File    string
+	Flag    string
+	Msg     string
+}
+
+// String gives a textual representation of the problem.
+func (p Problem) String() string {
+	s := p.Package
+	if p.File != "" {
+		s += " (" + p.File + ")"
+	}
+	if p.Flag != "" {
+		s += ": " + p.Flag
+	}
+	return s + ": " + p.Msg
+}
+
+// Report is a result of validating a package and its dependency tree.
+type Report struct {
+	Packages []*PC
+	Problems []Problem
+}
+
+// Valid reports whether no problems were found.
+func (r *Report) Valid() bool {
+	return len(r.Problems) == 0
+}
+
+// libPatterns gives, per GOOS, file name patterns of a library linked with
+// a -lNAME flag.
+var libPatterns = map[string][]string{
+	"darwin":  {"lib%s.dylib", "lib%s.a", "lib%s.*.dylib"},
+	"windows": {"lib%s.dll.a", "%s.dll.a", "lib%s.a", "%s.lib", "lib%s.dll", "%s.dll"},
+	"":        {"lib%s.so", "lib%s.a", "lib%s.so.*"},
+}
+
+// defaultLibPaths is a list of directories the linker searches for libraries
+// in addition to the ones passed with -L flags.
+var defaultLibPaths []string
+
+func findLib(goos, name string, dirs []string) bool {
+	if strings.HasPrefix(name, ":") {
+		// The -l:libfoo.so.1 flag names the file explicitly.
+		for _, dir := range dirs {
+			if existFile(filepath.Join(dir, name[1:])) == nil {
+				return true
+			}
+		}
+		return false
+	}
+	patterns, ok := libPatterns[goos]
+	if !ok {
+		patterns = libPatterns[""]
+	}
+	for _, dir := range dirs {
+		for _, pattern := range patterns {
+			m, _ := filepath.Glob(filepath.Join(dir, strings.Replace(pattern, "%s", name, -1)))
+			for _, file := range m {
+				if existFile(file) == nil {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (pkg *Pkg) check(pc *PC, goos string) (problems []Problem) {
+	report := func(flag, msg string) {
+		problems = append(problems, Problem{Package: pc.Name, File: pc.File, Flag: flag, Msg: msg})
+	}
+	cflags, libs := pc.Cflags, pc.Libs
+	if pkg.Static {
+		cflags = append(append([]string{}, cflags...), pc.CflagsPrivate...)
+		libs = append(append([]string{}, libs...), pc.LibsPrivate...)
+	}
+	for _, flag := range cflags {
+		if strings.HasPrefix(flag, "-I") && len(flag) > 2 {
+			if err := existDir(flag[2:]); err != nil {
+				report(flag, "include directory does not exist")
+			}
+		}
+	}
+	var dirs []string
+	for _, flag := range libs {
+		if strings.HasPrefix(flag, "-L") && len(flag) > 2 {
+			if err := existDir(flag[2:]); err != nil {
+				report(flag, "library directory does not exist")
+				continue
+			}
+			dirs = append(dirs, flag[2:])
+		}
+	}
+	dirs = append(dirs, defaultLibPaths...)
+	for _, flag := range libs {
+		if strings.HasPrefix(flag, "-l") && len(flag) > 2 {
+			if !findLib(goos, flag[2:], dirs) {
+				report(flag, "no matching library file found")
+			}
+		}
+	}
+	return
+}
+
+// Check validates the requested packages together with their whole dependency
+// tree. In addition to parsing each .pc file and resolving all the required
+// packages, which is what Resolve does, it verifies every -I and -L directory
+// exists and each -lNAME flag has a matching library file for the current
+// target. The returned error is non-nil only if the packages could not
+// be resolved, any other problems are listed by the report.
+func (pkg *Pkg) Check() (*Report, error) {
+	return pkg.CheckContext(context.Background())
+}
+
+// CheckContext is Check, which resolves the packages with ResolveContext.
+func (pkg *Pkg) CheckContext(ctx context.Context) (*Report, error) {
+	if err := pkg.ResolveContext(ctx); err != nil {
+		return nil, err
+	}
+	return pkg.report(runtime.GOOS), nil
+}
+
+// CheckTarget is CheckContext for the given GOOS_GOARCH target, which may
+// differ from the current one. The library files are matched with the naming
+// conventions of the target's GOOS. Unless the Pkg has its Source or Lookup
+// set, the packages are looked up within the lib/GOOS_GOARCH directories
+// of the $GOPATH workspaces.
+func (pkg *Pkg) CheckTarget(ctx context.Context, target string) (*Report, error) {
+	if _, ok := targets[target]; !ok {
+		return nil, fmt.Errorf("unsupported target %q", target)
+	}
+	goos, goarch := splittarget(target)
+	src := pkg.source(SourceFunc(withContext(func(name string) (*PC, error) {
+		return lookupGopathTarget(defaultGopath, name, goos, goarch)
+	})))
+	if err := pkg.resolve(ctx, src); err != nil {
+		return nil, err
+	}
+	return pkg.report(goos), nil
+}
+
+// splittarget splits the GOOS_GOARCH target.
+func splittarget(target string) (goos, goarch string) {
+	i := strings.Index(target, "_")
+	return target[:i], target[i+1:]
+}
+
+func (pkg *Pkg) report(goos string) *Report {
+	r := &Report{Packages: pkg.pc}
+	for _, pc := range pkg.pc {
+		if pkg.private[pc] && !pkg.Static {
+			// Private dependencies are linked with --static only, still
+			// their headers are needed.
+			p := *pc
+			p.Libs = nil
+			r.Problems = append(r.Problems, pkg.check(&p, goos)...)
+			continue
+		}
+		r.Problems = append(r.Problems, pkg.check(pc, goos)...)
+	}
+	return r
+}
@@ -0,0 +1,151 @@
+package pkgconfig
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestPkgCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pkgconfig")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	defer os.RemoveAll(dir)
+	include, lib := filepath.Join(dir, "include"), filepath.Join(dir, "lib")
+	if err = os.MkdirAll(include, 0755); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if err = os.MkdirAll(lib, 0755); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	pattern := libPatterns[""][0]
+	if p, ok := libPatterns[runtime.GOOS]; ok {
+		pattern = p[0]
+	}
+	for _, name := range []string{"foo", "bar"} {
+		file := filepath.Join(lib, strings.Replace(pattern, "%s", name, -1))
+		if err = ioutil.WriteFile(file, nil, 0644); err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+	}
+	if err = ioutil.WriteFile(filepath.Join(lib, "libbaz.so.1"), nil, 0644); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	all := map[string]*PC{
+		"foo": &PC{
+			Name:     "foo",
+			Requires: []Dep{{Name: "bar"}},
+			Cflags:   []string{"-I" + include},
+			Libs:     []string{"-L" + lib, "-lfoo", "-l:libbaz.so.1"},
+		},
+		"bar": &PC{
+			Name:            "bar",
+			RequiresPrivate: []Dep{{Name: "qux"}},
+			Cflags:          []string{"-I" + include, "-DBAR"},
+			Libs:            []string{"-L" + lib, "-lbar"},
+			LibsPrivate:     []string{"-lmissing"},
+		},
+		"qux": &PC{
+			Name:   "qux",
+			Cflags: []string{"-I" + filepath.Join(dir, "nonexistent")},
+			Libs:   []string{"-L" + filepath.Join(dir, "nonexistent"), "-lqux"},
+		},
+	}
+	lu := func(pkg string) (*PC, error) {
+		pc, ok := all[pkg]
+		if !ok {
+			return nil, errors.New("not found")
+		}
+		return pc, nil
+	}
+	cases := [...]struct {
+		static bool
+		exp    []Problem
+	}{{
+		false,
+		[]Problem{
+			{Package: "qux", Flag: "-I" + filepath.Join(dir, "nonexistent"), Msg: "include directory does not exist"},
+		},
+	}, {
+		true,
+		[]Problem{
+			{Package: "bar", Flag: "-lmissing", Msg: "no matching library file found"},
+			{Package: "qux", Flag: "-I" + filepath.Join(dir, "nonexistent"), Msg: "include directory does not exist"},
+			{Package: "qux", Flag: "-L" + filepath.Join(dir, "nonexistent"), Msg: "library directory does not exist"},
+			{Package: "qux", Flag: "-lqux", Msg: "no matching library file found"},
+		},
+	}}
+	for i, cas := range cases {
+		pkg := &Pkg{Packages: []string{"foo"}, Static: cas.static, Lookup: lu}
+		r, err := pkg.Check()
+		if err != nil {
+			t.Errorf("expected err=nil; was %q (i=%d)", err, i)
+			continue
+		}
+		if len(r.Packages) != 3 {
+			t.Errorf("expected len(r.Packages)=3; was %d (i=%d)", len(r.Packages), i)
+		}
+		if !reflect.DeepEqual(r.Problems, cas.exp) {
+			t.Errorf("expected r.Problems=%v; was %v (i=%d)", cas.exp, r.Problems, i)
+		}
+		if r.Valid() {
+			t.Errorf("expected r.Valid()=false (i=%d)", i)
+		}
+	}
+	pkg := &Pkg{Packages: []string{"foo", "missing"}, Lookup: lu}
+	if _, err := pkg.Check(); err == nil {
+		t.Error("expected err!=nil")
+	}
+}
+
+func TestPkgCheckTarget(t *testing.T) {
+	dir, restore := tempgopath(t)
+	defer restore()
+	lib := filepath.Join(dir, "lib", "windows_amd64", "libfoo")
+	files := map[string]string{
+		filepath.Join(dir, "include", "libfoo", "foo.h"): "",
+		filepath.Join(lib, "foo.dll"):                    "",
+		filepath.Join(lib, "libfoo.pc"): "Name: libfoo\nVersion: 1.0\nCflags: -I${GOPATH}/include/libfoo\n" +
+			"Libs: -L${GOPATH}/lib/${GOOS}_${GOARCH}/libfoo -lfoo\n",
+	}
+	for file, content := range files {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatalf("expected err=nil; was %q", err)
+		}
+	}
+	pkg := &Pkg{Packages: []string{"libfoo"}}
+	r, err := pkg.CheckTarget(context.Background(), "windows_amd64")
+	if err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if len(r.Packages) != 1 || !r.Valid() {
+		t.Errorf("expected libfoo to be valid; was %v", r.Problems)
+	}
+	if exp := "-L" + lib; len(r.Packages) == 1 && r.Packages[0].Libs[0] != exp {
+		t.Errorf("expected %s; was %v", exp, r.Packages[0].Libs)
+	}
+	// The .dll does not match the naming conventions of darwin.
+	if err = os.Rename(filepath.Join(dir, "lib", "windows_amd64"), filepath.Join(dir, "lib", "darwin_amd64")); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if r, err = pkg.CheckTarget(context.Background(), "darwin_amd64"); err != nil {
+		t.Fatalf("expected err=nil; was %q", err)
+	}
+	if exp := []Problem{{Package: "libfoo", File: filepath.Join(dir, "lib", "darwin_amd64", "libfoo", "libfoo.pc"),
+		Flag: "-lfoo", Msg: "no matching library file found"}}; !reflect.DeepEqual(r.Problems, exp) {
+		t.Errorf("expected r.Problems=%v; was %v", exp, r.Problems)
+	}
+	if _, err = pkg.CheckTarget(context.Background(), "linux_amd128"); err == nil {
+		t.Error("expected err!=nil")
+	}
+}
@@ -0,0 +1,174 @@
+package pkgconfig
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dep is a reference to a package with an optional version constraint, as
+// found in the Requires, Requires.private, Conflicts and Provides keywords:
+//
+//	Requires: libssh2 >= 1.4, zlib
+//
+// An empty Op matches any version of the package.
+type Dep struct {
+	Name    string
+	Op      string
+	Version string
+}
+
+var ops = map[string]func(int) bool{
+	"=":  func(n int) bool { return n == 0 },
+	"!=": func(n int) bool { return n != 0 },
+	"<":  func(n int) bool { return n < 0 },
+	"<=": func(n int) bool { return n <= 0 },
+	">":  func(n int) bool { return n > 0 },
+	">=": func(n int) bool { return n >= 0 },
+}
+
+// Match reports whether the given version satisfies the constraint.
+// No version satisfies a constraint with an unknown operator.
+func (d Dep) Match(version string) bool {
+	if d.Op == "" {
+		return true
+	}
+	op, ok := ops[d.Op]
+	if !ok {
+		return false
+	}
+	return op(compareVersion(version, d.Version))
+}
+
+// String gives a textual representation of the dependency, in the same format
+// it's read from a .pc file.
+func (d Dep) String() string {
+	if d.Op == "" {
+		return d.Name
+	}
+	return d.Name + " " + d.Op + " " + d.Version
+}
+
+func isop(c byte) bool {
+	return c == '<' || c == '>' || c == '=' || c == '!'
+}
+
+func issep(c byte) bool {
+	return c == ',' || c == ' ' || c == '\t'
+}
+
+func tokenize(s string) (tok []string) {
+	for i := 0; i < len(s); {
+		switch j := i; {
+		case issep(s[i]):
+			i++
+		case isop(s[i]):
+			for j < len(s) && isop(s[j]) {
+				j++
+			}
+			tok, i = append(tok, s[i:j]), j
+		default:
+			for j < len(s) && !isop(s[j]) && !issep(s[j]) {
+				j++
+			}
+			tok, i = append(tok, s[i:j]), j
+		}
+	}
+	return
+}
+
+func parseDeps(s string) (deps []Dep, err error) {
+	fail := func() error {
+		return fmt.Errorf("malformed dependency list: %q", s)
+	}
+	tok := tokenize(s)
+	for i := 0; i < len(tok); i++ {
+		if isop(tok[i][0]) {
+			return nil, fail()
+		}
+		dep := Dep{Name: tok[i]}
+		if i+1 < len(tok) && isop(tok[i+1][0]) {
+			if _, ok := ops[tok[i+1]]; !ok || i+2 >= len(tok) || isop(tok[i+2][0]) {
+				return nil, fail()
+			}
+			dep.Op, dep.Version = tok[i+1], tok[i+2]
+			i += 2
+		}
+		deps = append(deps, dep)
+	}
+	return
+}
+
+func joinDeps(deps []Dep) string {
+	s := make([]string, 0, len(deps))
+	for _, dep := range deps {
+		s = append(s, dep.String())
+	}
+	return strings.Join(s, ", ")
+}
+
+func isalnum(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isdigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// compareVersion compares two versions with the rpmvercmp algorithm used
+// by the original pkg-config. It returns -1, 0 or 1 if a is respectively
+// older, the same as or newer than b.
+func compareVersion(a, b string) int {
+	if a == b {
+		return 0
+	}
+	for {
+		for len(a) != 0 && !isalnum(a[0]) {
+			a = a[1:]
+		}
+		for len(b) != 0 && !isalnum(b[0]) {
+			b = b[1:]
+		}
+		if len(a) == 0 || len(b) == 0 {
+			break
+		}
+		var (
+			digit  = isdigit(a[0])
+			accept = func(c byte) bool { return isdigit(c) == digit && isalnum(c) }
+			i, j   int
+		)
+		for i < len(a) && accept(a[i]) {
+			i++
+		}
+		for j < len(b) && accept(b[j]) {
+			j++
+		}
+		if j == 0 {
+			// Numeric segments are always newer than alpha ones.
+			if digit {
+				return 1
+			}
+			return -1
+		}
+		x, y := a[:i], b[:j]
+		a, b = a[i:], b[j:]
+		if digit {
+			x, y = strings.TrimLeft(x, "0"), strings.TrimLeft(y, "0")
+			if len(x) != len(y) {
+				if len(x) > len(y) {
+					return 1
+				}
+				return -1
+			}
+		}
+		if n := strings.Compare(x, y); n != 0 {
+			return n
+		}
+	}
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return -1
+	}
+	return 1
+}
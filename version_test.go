@@ -0,0 +1,100 @@
+package pkgconfig
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareVersion(t *testing.T) {
+	cases := [...]struct {
+		a, b string
+		exp  int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.1", -1},
+		{"1.1", "1.0", 1},
+		{"1.10", "1.9", 1},
+		{"1.01", "1.1", 0},
+		{"1.0.1", "1.0", 1},
+		{"1.0", "1.0.1", -1},
+		{"2.0a", "2.0", 1},
+		{"2.0a", "2.0b", -1},
+		{"2.0.1", "2.0a", 1},
+		{"0.20.0", "0.20.0", 0},
+		{"1_0", "1.0", 0},
+		{"", "1.0", -1},
+	}
+	for i, cas := range cases {
+		if n := compareVersion(cas.a, cas.b); n != cas.exp {
+			t.Errorf("expected n=%d; was %d (i=%d)", cas.exp, n, i)
+		}
+	}
+}
+
+func TestParseDeps(t *testing.T) {
+	cases := [...]struct {
+		s   string
+		exp []Dep
+	}{{
+		"",
+		nil,
+	}, {
+		"zlib",
+		[]Dep{{Name: "zlib"}},
+	}, {
+		"libssh2 >= 1.4, zlib",
+		[]Dep{{"libssh2", ">=", "1.4"}, {Name: "zlib"}},
+	}, {
+		"a>=1.0,b<2 c != 3  d",
+		[]Dep{{"a", ">=", "1.0"}, {"b", "<", "2"}, {"c", "!=", "3"}, {Name: "d"}},
+	}, {
+		"gtk+-3.0 = 3.24.1",
+		[]Dep{{"gtk+-3.0", "=", "3.24.1"}},
+	}}
+	for i, cas := range cases {
+		deps, err := parseDeps(cas.s)
+		if err != nil {
+			t.Errorf("expected err=nil; was %q (i=%d)", err, i)
+			continue
+		}
+		if !reflect.DeepEqual(deps, cas.exp) {
+			t.Errorf("expected deps=%v; was %v (i=%d)", cas.exp, deps, i)
+		}
+	}
+	casesErr := [...]string{
+		">= 1.0",
+		"a >=",
+		"a => 1.0",
+		"a >= >= 1.0",
+		"a = 1.0 = 2.0",
+	}
+	for i, cas := range casesErr {
+		if _, err := parseDeps(cas); err == nil {
+			t.Errorf("expected err!=nil (i=%d)", i)
+		}
+	}
+}
+
+func TestDepMatch(t *testing.T) {
+	cases := [...]struct {
+		dep     Dep
+		version string
+		exp     bool
+	}{
+		{Dep{Name: "a"}, "", true},
+		{Dep{"a", "=", "1.0"}, "1.0", true},
+		{Dep{"a", "=", "1.0"}, "1.1", false},
+		{Dep{"a", "!=", "1.0"}, "1.1", true},
+		{Dep{"a", ">=", "1.0"}, "1.0", true},
+		{Dep{"a", ">", "1.0"}, "1.0", false},
+		{Dep{"a", "<", "1.10"}, "1.9", true},
+		{Dep{"a", "<=", "1.9"}, "1.10", false},
+		{Dep{"a", "==", "1.0"}, "1.0", false},
+		{Dep{"a", "~>", "1.0"}, "1.1", false},
+	}
+	for i, cas := range cases {
+		if ok := cas.dep.Match(cas.version); ok != cas.exp {
+			t.Errorf("expected ok=%v; was %v (i=%d)", cas.exp, ok, i)
+		}
+	}
+}